@@ -15,9 +15,78 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/enrichment/cache": {
+            "get": {
+                "description": "Report how many enrichment lookups were answered from memory, from the database or by the providers",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Enrichment cache stats",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.CacheStats"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/enrichment/providers": {
+            "get": {
+                "description": "Report the circuit breaker state of each enrichment provider",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Enrichment provider status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/service.ProviderStatus"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/people/purge": {
+            "post": {
+                "description": "Permanently remove people soft deleted for longer than the configured retention",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Purge deleted people",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.PurgeResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    }
+                }
+            }
+        },
         "/people": {
             "get": {
-                "description": "Retrieve a list of people based on query parameters",
+                "description": "Retrieve a page of people based on query parameters.\nNeighbouring pages are advertised in the Link header.",
                 "consumes": [
                     "application/json"
                 ],
@@ -29,6 +98,12 @@ const docTemplate = `{
                 ],
                 "summary": "Get people by filters",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Fuzzy search over name and surname, ranked by similarity unless sort is given",
+                        "name": "q",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "description": "Filter by name",
@@ -48,26 +123,136 @@ const docTemplate = `{
                         "in": "query"
                     },
                     {
-                        "type": "string",
-                        "description": "Filter by gender",
+                        "type": "array",
+                        "items": {
+                            "type": "integer"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "Filter by any of the ids",
+                        "name": "id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "Filter by any of the genders",
                         "name": "gender",
                         "in": "query"
                     },
                     {
-                        "type": "string",
-                        "description": "Filter by nationality",
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "Filter by any of the nationalities",
                         "name": "nationality",
                         "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum age, inclusive",
+                        "name": "age_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum age, inclusive",
+                        "name": "age_max",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created at or after (RFC3339 or YYYY-MM-DD)",
+                        "name": "created_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created before (RFC3339 or YYYY-MM-DD)",
+                        "name": "created_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Updated at or after (RFC3339 or YYYY-MM-DD)",
+                        "name": "updated_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Updated before (RFC3339 or YYYY-MM-DD)",
+                        "name": "updated_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated sort columns, prefix with - for descending (e.g. surname,-age)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of people to return",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of people to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from next_cursor; pass it empty to start keyset pagination",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to return (e.g. id,name,nationality)",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Also list soft deleted people",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Set to false to get a bare array instead of a page",
+                        "name": "envelope",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/repo.Person"
-                            }
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/server.PeoplePage"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "items": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/repo.Person"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "400": {
@@ -105,6 +290,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/service.CreatePersonReq"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who makes the change, recorded in the history",
+                        "name": "X-Actor",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -120,42 +311,78 @@ const docTemplate = `{
                             "$ref": "#/definitions/server.ErrorWrapper"
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/server.ErrorWrapper"
                         }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
                     }
                 }
-            }
-        },
-        "/people/{id}": {
-            "get": {
-                "description": "Retrieve a single person by their ID",
-                "consumes": [
-                    "application/json"
-                ],
+            },
+            "delete": {
+                "description": "Soft delete every person matching the GET /people query filters in one transaction.\nWithout any filter the request must set confirm=true. limit, offset, cursor, sort and fields are rejected.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "People"
                 ],
-                "summary": "Get a person by ID",
+                "summary": "Delete people by filters",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Person ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "type": "boolean",
+                        "description": "Required to delete everyone when no filter is given",
+                        "name": "confirm",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only report how many people would be deleted",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who makes the change, recorded in the history",
+                        "name": "X-Actor",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/repo.Person"
+                            "$ref": "#/definitions/server.BulkResponse"
                         }
                     },
                     "400": {
@@ -164,12 +391,6 @@ const docTemplate = `{
                             "$ref": "#/definitions/server.ErrorWrapper"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
-                        "schema": {
-                            "$ref": "#/definitions/server.ErrorWrapper"
-                        }
-                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -178,8 +399,8 @@ const docTemplate = `{
                     }
                 }
             },
-            "delete": {
-                "description": "Delete a person from the database by their ID",
+            "patch": {
+                "description": "Apply the same changes to every person matching the GET /people query filters in one transaction.\nWithout any filter the request must set confirm=true. limit, offset, cursor, sort and fields are rejected.",
                 "consumes": [
                     "application/json"
                 ],
@@ -189,19 +410,42 @@ const docTemplate = `{
                 "tags": [
                     "People"
                 ],
-                "summary": "Delete a person by ID",
+                "summary": "Update people by filters",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Person ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Changes to apply",
+                        "name": "person",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.UpdatePersonReq"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Required to update everyone when no filter is given",
+                        "name": "confirm",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only report how many people would be updated",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who makes the change, recorded in the history",
+                        "name": "X-Actor",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.BulkResponse"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -209,8 +453,14 @@ const docTemplate = `{
                             "$ref": "#/definitions/server.ErrorWrapper"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
                         "schema": {
                             "$ref": "#/definitions/server.ErrorWrapper"
                         }
@@ -222,9 +472,11 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "patch": {
-                "description": "Update the details of a person by their ID",
+            }
+        },
+        "/people/batch": {
+            "post": {
+                "description": "Validate, enrich and create many people at once.\nIn atomic mode (default) either every person is created in one transaction or none is;\nin best_effort mode each person is created on its own. Results are reported per item.\nIn atomic mode a name and surname repeated in the batch or already taken fails its item before any lookup.",
                 "consumes": [
                     "application/json"
                 ],
@@ -234,30 +486,49 @@ const docTemplate = `{
                 "tags": [
                     "People"
                 ],
-                "summary": "Update a person by ID",
+                "summary": "Create people in batch",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Person ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Updated person details",
-                        "name": "person",
+                        "description": "People to create",
+                        "name": "people",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/service.UpdatePersonReq"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/service.CreatePersonReq"
+                            }
                         }
+                    },
+                    {
+                        "enum": [
+                            "atomic",
+                            "best_effort"
+                        ],
+                        "type": "string",
+                        "default": "atomic",
+                        "description": "atomic or best_effort",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who makes the change, recorded in the history",
+                        "name": "X-Actor",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "All people created",
                         "schema": {
-                            "$ref": "#/definitions/repo.Person"
+                            "$ref": "#/definitions/server.BatchCreateResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Some people were not created",
+                        "schema": {
+                            "$ref": "#/definitions/server.BatchCreateResponse"
                         }
                     },
                     "400": {
@@ -266,8 +537,14 @@ const docTemplate = `{
                             "$ref": "#/definitions/server.ErrorWrapper"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
                         "schema": {
                             "$ref": "#/definitions/server.ErrorWrapper"
                         }
@@ -280,17 +557,467 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "repo.Person": {
-            "type": "object",
-            "properties": {
-                "age": {
-                    "type": "integer"
-                },
-                "created_at": {
-                    "type": "string"
+        },
+        "/people/export": {
+            "get": {
+                "description": "Stream every person matching the GET /people query filters as CSV or NDJSON.",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "People"
+                ],
+                "summary": "Export people",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "ndjson"
+                        ],
+                        "type": "string",
+                        "default": "csv",
+                        "description": "Export format",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to export (e.g. id,name,nationality)",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    }
+                }
+            }
+        },
+        "/people/import": {
+            "post": {
+                "description": "Register the people listed in a CSV file with the columns name, surname and optionally age, gender and nationality.\nThe file is sent either as the multipart form field \"file\" or as a text/csv body.\nExisting or repeated people are skipped and invalid rows are rejected, each with a reason.",
+                "consumes": [
+                    "multipart/form-data",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "People"
+                ],
+                "summary": "Import people from CSV",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "CSV file",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only validate the file without creating anyone",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who makes the change, recorded in the history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.ImportReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    }
+                }
+            }
+        },
+        "/people/{id}": {
+            "get": {
+                "description": "Retrieve a single person by their ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "People"
+                ],
+                "summary": "Get a person by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Person ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to return (e.g. id,name,nationality)",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/repo.Person"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the person"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Soft delete a person by their ID. It can be restored until it is purged.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "People"
+                ],
+                "summary": "Delete a person by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Person ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who makes the change, recorded in the history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update the details of a person by their ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "People"
+                ],
+                "summary": "Update a person by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Person ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated person details",
+                        "name": "person",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.UpdatePersonReq"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who makes the change, recorded in the history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the update is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/repo.Person"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the person"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    }
+                }
+            }
+        },
+        "/people/{id}/history": {
+            "get": {
+                "description": "Retrieve the recorded changes of a person, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "People"
+                ],
+                "summary": "Get the change history of a person",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Person ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of entries to return",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of entries to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.HistoryPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    }
+                }
+            }
+        },
+        "/people/{id}/restore": {
+            "post": {
+                "description": "Restore a soft deleted person by their ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "People"
+                ],
+                "summary": "Restore a deleted person",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Person ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who makes the change, recorded in the history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/repo.Person"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorWrapper"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "breaker.State": {
+            "type": "string",
+            "enum": [
+                "closed",
+                "open",
+                "half-open"
+            ],
+            "x-enum-varnames": [
+                "Closed",
+                "Open",
+                "HalfOpen"
+            ]
+        },
+        "repo.Person": {
+            "type": "object",
+            "properties": {
+                "age": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "description": "DeletedAt is set while the person is soft deleted.",
+                    "type": "string"
                 },
                 "gender": {
                     "type": "string"
@@ -309,6 +1036,64 @@ const docTemplate = `{
                 },
                 "updated_at": {
                     "type": "string"
+                },
+                "version": {
+                    "description": "Version is incremented by every write, including soft deletes and\nrestores, and serves as the ETag.",
+                    "type": "integer"
+                }
+            }
+        },
+        "repo.PersonHistory": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "actor": {
+                    "type": "string"
+                },
+                "after": {
+                    "type": "object"
+                },
+                "before": {
+                    "type": "object"
+                },
+                "changed_fields": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "person_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "server.BatchCreateResponse": {
+            "type": "object",
+            "properties": {
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.BatchItemResult"
+                    }
+                }
+            }
+        },
+        "server.BulkResponse": {
+            "type": "object",
+            "properties": {
+                "affected": {
+                    "type": "integer"
+                },
+                "dry_run": {
+                    "type": "boolean"
                 }
             }
         },
@@ -316,7 +1101,6 @@ const docTemplate = `{
             "type": "object",
             "properties": {
                 "errors": {
-                    "description": "@Example: {\"name\":\"Name is required\",\"surname\":\"Surname is required\"}",
                     "type": "object",
                     "additionalProperties": {
                         "type": "string"
@@ -324,6 +1108,99 @@ const docTemplate = `{
                 }
             }
         },
+        "server.HistoryPage": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.PersonHistory"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "server.PeoplePage": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "description": "Items holds either *repo.Person values or, when a sparse fieldset\nwas requested, maps with only those fields.",
+                    "type": "array",
+                    "items": {}
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "server.PurgeResponse": {
+            "type": "object",
+            "properties": {
+                "purged": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.BatchItemResult": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "person": {
+                    "$ref": "#/definitions/repo.Person"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.CacheStats": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "description": "Enabled is false when lookups are not cached at all.",
+                    "type": "boolean"
+                },
+                "memory_hits": {
+                    "description": "MemoryHits were answered by the in-process cache, StoreHits by the\npersistent one and Misses by the provider.",
+                    "type": "integer"
+                },
+                "misses": {
+                    "type": "integer"
+                },
+                "size": {
+                    "description": "Size is the number of answers held in process.",
+                    "type": "integer"
+                },
+                "store_hits": {
+                    "type": "integer"
+                }
+            }
+        },
         "service.CreatePersonReq": {
             "type": "object",
             "properties": {
@@ -335,6 +1212,94 @@ const docTemplate = `{
                 }
             }
         },
+        "service.ImportReport": {
+            "type": "object",
+            "properties": {
+                "accepted": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.ImportRow"
+                    }
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "rejected": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.ImportRow"
+                    }
+                },
+                "skipped": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.ImportRow"
+                    }
+                }
+            }
+        },
+        "service.ImportRow": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "line": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "person": {
+                    "$ref": "#/definitions/repo.Person"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "surname": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.ProviderStatus": {
+            "type": "object",
+            "properties": {
+                "failures": {
+                    "description": "Failures is the number of consecutive failed calls.",
+                    "type": "integer"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "quota": {
+                    "$ref": "#/definitions/service.QuotaStatus"
+                },
+                "retry_at": {
+                    "description": "RetryAt is when an open breaker lets a trial call through.",
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/breaker.State"
+                }
+            }
+        },
+        "service.QuotaStatus": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer"
+                },
+                "remaining": {
+                    "type": "integer"
+                },
+                "reset_at": {
+                    "type": "string"
+                }
+            }
+        },
         "service.UpdatePersonReq": {
             "type": "object",
             "properties": {
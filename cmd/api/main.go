@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 
 	_ "github.com/TheTeemka/TaskNameManager/cmd/api/docs"
@@ -11,16 +14,75 @@ import (
 	"github.com/TheTeemka/TaskNameManager/internal/server"
 	"github.com/TheTeemka/TaskNameManager/internal/service"
 	"github.com/TheTeemka/TaskNameManager/pkg/utils"
+	"github.com/jmoiron/sqlx"
 )
 
 func main() {
 	slog.SetDefault(utils.Mylog(os.Stdout, slog.LevelDebug))
 	cfg := config.MustLoad()
+	ctx := context.Background()
 
-	db := database.OpenPostgres(cfg.DBString)
+	var db *sqlx.DB
+	switch cfg.StorageDriver {
+	case config.StorageDriverPostgres:
+		db = database.OpenPostgres(cfg.DBString)
+	case config.StorageDriverSQLite:
+		db = database.OpenSQLite(cfg.SQLiteDSN)
+	}
 
-	personRepo := repo.NewPersonRepository(db)
-	personService := service.NewPersonService(personRepo)
+	if len(os.Args) > 1 {
+		if os.Args[1] != "migrate" || len(os.Args) != 3 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			os.Exit(2)
+		}
+		if db == nil {
+			fmt.Fprintf(os.Stderr, "storage driver %q has no migrations\n", cfg.StorageDriver)
+			os.Exit(1)
+		}
+
+		migrator, err := newMigrator(cfg, db)
+		if err == nil {
+			err = runMigrate(ctx, migrator, os.Args[2])
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.AutoMigrate && db != nil {
+		migrator, err := newMigrator(cfg, db)
+		if err == nil {
+			err = autoMigrate(ctx, migrator)
+		}
+		if err != nil {
+			slog.Error("could not migrate the database", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var personRepo repo.PersonRepository
+	var enrichmentRepo repo.EnrichmentRepository
+	switch cfg.StorageDriver {
+	case config.StorageDriverSQLite:
+		personRepo = repo.NewSQLitePersonRepository(db)
+		enrichmentRepo = repo.NewSQLiteEnrichmentRepository(db)
+	case config.StorageDriverMemory:
+		personRepo = repo.NewMemoryPersonRepository()
+	default:
+		personRepo = repo.NewPersonRepository(db)
+		enrichmentRepo = repo.NewEnrichmentRepository(db)
+	}
+	var enricher service.Enricher = service.NewHTTPEnricher(&http.Client{},
+		service.ProviderConfig(cfg.Agify),
+		service.ProviderConfig(cfg.Genderize),
+		service.ProviderConfig(cfg.Nationalize),
+	)
+	if cfg.EnrichCacheTTL > 0 {
+		enricher = service.NewCachedEnricher(enricher, enrichmentRepo, cfg.EnrichCacheSize, cfg.EnrichCacheTTL)
+	}
+	personService := service.NewPersonService(personRepo, enricher, cfg.EnrichTimeout, cfg.PurgeRetention)
 
 	srv := server.NewServer(cfg.ServerPort, personService)
 	srv.Serve()
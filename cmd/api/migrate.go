@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/TheTeemka/TaskNameManager/internal/config"
+	"github.com/TheTeemka/TaskNameManager/internal/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+)
+
+const migrateUsage = "usage: api migrate up|down|status|redo"
+
+func newMigrator(cfg *config.Config, db *sqlx.DB) (*goose.Provider, error) {
+	if cfg.StorageDriver == config.StorageDriverSQLite {
+		return database.NewSQLiteMigrator(db)
+	}
+	return database.NewPostgresMigrator(db)
+}
+
+// runMigrate runs one of the migrate subcommands and prints its outcome:
+// up applies all pending migrations, down rolls back the latest one, redo
+// rolls it back and applies it again and status lists every migration.
+func runMigrate(ctx context.Context, p *goose.Provider, command string) error {
+	switch command {
+	case "up":
+		results, err := p.Up(ctx)
+		for _, res := range results {
+			fmt.Println(res)
+		}
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Println("no migrations to apply")
+		}
+
+	case "down":
+		res, err := p.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println(res)
+
+	case "redo":
+		res, err := p.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println(res)
+
+		res, err = p.UpByOne(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println(res)
+
+	case "status":
+		statuses, err := p.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-25s %s\n", "Applied At", "Migration")
+		for _, s := range statuses {
+			appliedAt := "Pending"
+			if s.State == goose.StateApplied {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%-25s %s\n", appliedAt, s.Source.Path)
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate command %q, %s", command, migrateUsage)
+	}
+	return nil
+}
+
+// autoMigrate applies pending migrations on startup.
+func autoMigrate(ctx context.Context, p *goose.Provider) error {
+	results, err := p.Up(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to migrate: %w", err)
+	}
+	for _, res := range results {
+		slog.Info("applied migration", "migration", res.Source.Path, "duration", res.Duration)
+	}
+	return nil
+}
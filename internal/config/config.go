@@ -2,17 +2,74 @@ package config
 
 import (
 	"log"
+	"net/url"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/TheTeemka/TaskNameManager/pkg/validator"
 	"github.com/joho/godotenv"
 )
 
+const (
+	StorageDriverPostgres = "postgres"
+	StorageDriverSQLite   = "sqlite"
+	StorageDriverMemory   = "memory"
+)
+
+// ProviderConfig locates an enrichment provider and says how failing
+// calls to it are retried and cut off.
+type ProviderConfig struct {
+	BaseURL          string
+	APIKey           string
+	Timeout          time.Duration
+	Retries          int
+	RetryBackoff     time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	ThrottleBelow    float64
+}
+
 type Config struct {
 	ServerPort string
-	DBString   string
+	// StorageDriver selects where people are kept: "postgres", "sqlite"
+	// or "memory".
+	StorageDriver string
+	DBString      string
+	SQLiteDSN     string
+	// AutoMigrate applies pending migrations when the server starts.
+	AutoMigrate bool
+	// PurgeRetention is how long soft deleted people are kept before an
+	// admin purge removes them for good.
+	PurgeRetention time.Duration
+	// EnrichTimeout bounds the enrichment of one person across all
+	// providers.
+	EnrichTimeout time.Duration
+	// EnrichCacheTTL is how long enrichment answers are reused; zero
+	// disables the cache. EnrichCacheSize bounds the answers held in
+	// memory.
+	EnrichCacheTTL  time.Duration
+	EnrichCacheSize int
+	// Agify, Genderize and Nationalize are the providers people are
+	// enriched with.
+	Agify       ProviderConfig
+	Genderize   ProviderConfig
+	Nationalize ProviderConfig
 }
 
+const (
+	defaultPurgeRetention   = 30 * 24 * time.Hour
+	defaultEnrichTimeout    = 10 * time.Second
+	defaultEnrichCacheTTL   = 30 * 24 * time.Hour
+	defaultEnrichCacheSize  = 10000
+	defaultProviderTimeout  = 5 * time.Second
+	defaultRetries          = 2
+	defaultRetryBackoff     = 200 * time.Millisecond
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+	defaultThrottleBelow    = 0.1
+)
+
 func MustLoad() *Config {
 	err := godotenv.Load(".env")
 	if err != nil {
@@ -20,11 +77,48 @@ func MustLoad() *Config {
 	}
 
 	cfg := &Config{
-		ServerPort: os.Getenv("SERVER_PORT"),
-		DBString:   os.Getenv("PSQL_DBSTRING"),
+		ServerPort:      os.Getenv("SERVER_PORT"),
+		StorageDriver:   os.Getenv("STORAGE_DRIVER"),
+		DBString:        os.Getenv("PSQL_DBSTRING"),
+		SQLiteDSN:       os.Getenv("SQLITE_DSN"),
+		PurgeRetention:  defaultPurgeRetention,
+		EnrichTimeout:   defaultEnrichTimeout,
+		EnrichCacheTTL:  defaultEnrichCacheTTL,
+		EnrichCacheSize: defaultEnrichCacheSize,
+	}
+	if cfg.StorageDriver == "" {
+		cfg.StorageDriver = StorageDriverPostgres
 	}
 
 	v := validator.New()
+	if s := os.Getenv("AUTO_MIGRATE"); s != "" {
+		var err error
+		cfg.AutoMigrate, err = strconv.ParseBool(s)
+		v.Check(err == nil, "Auto Migrate", "must be true or false")
+	}
+	if s := os.Getenv("PURGE_RETENTION"); s != "" {
+		var err error
+		cfg.PurgeRetention, err = time.ParseDuration(s)
+		v.Check(err == nil, "Purge Retention", "must be a duration such as 720h")
+	}
+	if s := os.Getenv("ENRICH_TIMEOUT"); s != "" {
+		var err error
+		cfg.EnrichTimeout, err = time.ParseDuration(s)
+		v.Check(err == nil && cfg.EnrichTimeout >= 0, "Enrich Timeout", "must be a duration such as 10s")
+	}
+	if s := os.Getenv("ENRICH_CACHE_TTL"); s != "" {
+		var err error
+		cfg.EnrichCacheTTL, err = time.ParseDuration(s)
+		v.Check(err == nil && cfg.EnrichCacheTTL >= 0, "Enrich Cache TTL", "must be a duration such as 720h")
+	}
+	if s := os.Getenv("ENRICH_CACHE_SIZE"); s != "" {
+		var err error
+		cfg.EnrichCacheSize, err = strconv.Atoi(s)
+		v.Check(err == nil && cfg.EnrichCacheSize > 0, "Enrich Cache Size", "must be a positive integer")
+	}
+	cfg.Agify = loadProvider("AGIFY", "https://api.agify.io", v)
+	cfg.Genderize = loadProvider("GENDERIZE", "https://api.genderize.io", v)
+	cfg.Nationalize = loadProvider("NATIONALIZE", "https://api.nationalize.io", v)
 	if cfg.Validate(v); !v.Valid() {
 		log.Fatal(v)
 	}
@@ -32,9 +126,74 @@ func MustLoad() *Config {
 	return cfg
 }
 
+// loadProvider reads the <prefix>_URL, <prefix>_API_KEY, <prefix>_TIMEOUT,
+// <prefix>_RETRIES, <prefix>_RETRY_BACKOFF, <prefix>_BREAKER_THRESHOLD,
+// <prefix>_BREAKER_COOLDOWN and <prefix>_THROTTLE_BELOW variables of a
+// provider.
+func loadProvider(prefix, defaultURL string, v *validator.Validator) ProviderConfig {
+	p := ProviderConfig{
+		BaseURL:          os.Getenv(prefix + "_URL"),
+		APIKey:           os.Getenv(prefix + "_API_KEY"),
+		Timeout:          defaultProviderTimeout,
+		Retries:          defaultRetries,
+		RetryBackoff:     defaultRetryBackoff,
+		BreakerThreshold: defaultBreakerThreshold,
+		BreakerCooldown:  defaultBreakerCooldown,
+		ThrottleBelow:    defaultThrottleBelow,
+	}
+	if p.BaseURL == "" {
+		p.BaseURL = defaultURL
+	}
+	if s := os.Getenv(prefix + "_TIMEOUT"); s != "" {
+		var err error
+		p.Timeout, err = time.ParseDuration(s)
+		v.Check(err == nil && p.Timeout >= 0, prefix+" Timeout", "must be a duration such as 5s")
+	}
+	if s := os.Getenv(prefix + "_RETRIES"); s != "" {
+		var err error
+		p.Retries, err = strconv.Atoi(s)
+		v.Check(err == nil && p.Retries >= 0, prefix+" Retries", "must be a non-negative integer")
+	}
+	if s := os.Getenv(prefix + "_RETRY_BACKOFF"); s != "" {
+		var err error
+		p.RetryBackoff, err = time.ParseDuration(s)
+		v.Check(err == nil && p.RetryBackoff >= 0, prefix+" Retry Backoff", "must be a duration such as 200ms")
+	}
+	if s := os.Getenv(prefix + "_BREAKER_THRESHOLD"); s != "" {
+		var err error
+		p.BreakerThreshold, err = strconv.Atoi(s)
+		v.Check(err == nil && p.BreakerThreshold >= 0, prefix+" Breaker Threshold", "must be a non-negative integer")
+	}
+	if s := os.Getenv(prefix + "_BREAKER_COOLDOWN"); s != "" {
+		var err error
+		p.BreakerCooldown, err = time.ParseDuration(s)
+		v.Check(err == nil && p.BreakerCooldown >= 0, prefix+" Breaker Cooldown", "must be a duration such as 30s")
+	}
+	if s := os.Getenv(prefix + "_THROTTLE_BELOW"); s != "" {
+		var err error
+		p.ThrottleBelow, err = strconv.ParseFloat(s, 64)
+		v.Check(err == nil && p.ThrottleBelow >= 0 && p.ThrottleBelow <= 1, prefix+" Throttle Below", "must be a fraction between 0 and 1")
+	}
+
+	u, err := url.Parse(p.BaseURL)
+	v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+		prefix+" URL", "must be an http or https URL")
+	return p
+}
+
 func (cfg *Config) Validate(v *validator.Validator) {
-	v.CheckWithRules("Config DBString", cfg.DBString, validator.IsNotEmpty)
+	switch cfg.StorageDriver {
+	case StorageDriverPostgres:
+		v.CheckWithRules("Config DBString", cfg.DBString, validator.IsNotEmpty)
+	case StorageDriverSQLite:
+		v.CheckWithRules("Config SQLiteDSN", cfg.SQLiteDSN, validator.IsNotEmpty)
+	case StorageDriverMemory:
+	default:
+		v.AddError("Storage Driver", "must be postgres, sqlite or memory")
+	}
 
 	v.CheckWithRules("Server Port", cfg.ServerPort, validator.IsNotEmpty)
 	v.CheckWithRules("Server Port", cfg.ServerPort[1:], validator.IsInt(0))
+
+	v.Check(cfg.PurgeRetention >= 0, "Purge Retention", "must not be negative")
 }
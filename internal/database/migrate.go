@@ -0,0 +1,48 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// migrations holds the Postgres migrations at its root and the SQLite ones
+// under sqlite/.
+//
+//go:embed migrations/*.sql migrations/sqlite/*.sql
+var migrations embed.FS
+
+// NewPostgresMigrator returns a goose provider for the embedded Postgres
+// migrations. Up and down hold a session advisory lock, so replicas that
+// migrate at the same time run the migrations once, one after another.
+func NewPostgresMigrator(db *sqlx.DB) (*goose.Provider, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration lock: %w", err)
+	}
+
+	return newMigrator(goose.DialectPostgres, db, "migrations", goose.WithSessionLocker(locker))
+}
+
+// NewSQLiteMigrator returns a goose provider for the embedded SQLite
+// migrations.
+func NewSQLiteMigrator(db *sqlx.DB) (*goose.Provider, error) {
+	return newMigrator(goose.DialectSQLite3, db, "migrations/sqlite")
+}
+
+func newMigrator(dialect goose.Dialect, db *sqlx.DB, dir string, opts ...goose.ProviderOption) (*goose.Provider, error) {
+	fsys, err := fs.Sub(migrations, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := goose.NewProvider(dialect, db.DB, fsys, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return p, nil
+}
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS people_name_trgm_idx ON people USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS people_surname_trgm_idx ON people USING GIN (surname gin_trgm_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS people_surname_trgm_idx;
+DROP INDEX IF EXISTS people_name_trgm_idx;
+-- +goose StatementEnd
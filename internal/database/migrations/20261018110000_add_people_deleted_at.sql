@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE people ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- Deleted people must not block registering the same name again.
+ALTER TABLE people DROP CONSTRAINT IF EXISTS unique_name_surname;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_name_surname ON people (name, surname) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS people_deleted_at_idx ON people (deleted_at) WHERE deleted_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS people_deleted_at_idx;
+DROP INDEX IF EXISTS unique_name_surname;
+DELETE FROM people WHERE deleted_at IS NOT NULL;
+ALTER TABLE people ADD CONSTRAINT unique_name_surname UNIQUE (name, surname);
+ALTER TABLE people DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Timestamps are stored as fixed width UTC text, see repo.sqliteTimeFormat.
+CREATE TABLE IF NOT EXISTS people (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(20) NOT NULL,
+    surname VARCHAR(20) NOT NULL,
+    age INT NOT NULL,
+    gender VARCHAR(10) NOT NULL,
+    nationality VARCHAR(10) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    deleted_at TIMESTAMP,
+    version INT NOT NULL DEFAULT 1
+);
+
+-- Deleted people must not block registering the same name again.
+CREATE UNIQUE INDEX IF NOT EXISTS unique_name_surname ON people (name, surname) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS people_deleted_at_idx ON people (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS people_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    person_id INTEGER NOT NULL REFERENCES people (id) ON DELETE CASCADE,
+    action VARCHAR(10) NOT NULL,
+    actor VARCHAR(100) NOT NULL DEFAULT '',
+    before TEXT,
+    after TEXT,
+    changed_fields TEXT NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS people_history_person_id_idx ON people_history (person_id, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS people_history;
+DROP TABLE IF EXISTS people;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS name_enrichment (
+    name VARCHAR(20) NOT NULL,
+    attribute VARCHAR(20) NOT NULL,
+    value VARCHAR(20) NOT NULL,
+    fetched_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    PRIMARY KEY (name, attribute)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS name_enrichment;
+-- +goose StatementEnd
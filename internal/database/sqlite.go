@@ -0,0 +1,31 @@
+package database
+
+import (
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+// OpenSQLite opens the SQLite database at dsn, e.g. "file:people.db".
+// Foreign keys are enabled and a single connection is used, so that write
+// transactions are serialized without row locks.
+func OpenSQLite(dsn string) *sqlx.DB {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	dsn += sep + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+
+	db, err := sqlx.Open("sqlite", dsn)
+	if err != nil {
+		panic(err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if err = db.Ping(); err != nil {
+		panic(err)
+	}
+
+	return db
+}
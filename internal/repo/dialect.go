@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"database/sql/driver"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// Dialect is the SQL flavour of the database a repository talks to. Both
+// dialects use $1..$n placeholders; they differ in the functions and
+// operators available.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// sqliteTimeFormat is how timestamps are stored in SQLite. Fixed width UTC
+// text keeps comparisons between stored values and arguments correct.
+const sqliteTimeFormat = "2006-01-02 15:04:05.000"
+
+// now returns the expression for the current timestamp.
+func (d Dialect) now() string {
+	if d == SQLite {
+		return "strftime('%Y-%m-%d %H:%M:%f', 'now')"
+	}
+	return "NOW()"
+}
+
+// forUpdate returns the row locking suffix of a SELECT. SQLite locks the
+// whole database for the duration of a write transaction instead.
+func (d Dialect) forUpdate() string {
+	if d == SQLite {
+		return ""
+	}
+	return " FOR UPDATE"
+}
+
+// arg converts value into the form it is stored in.
+func (d Dialect) arg(value any) any {
+	if t, ok := value.(time.Time); ok && d == SQLite {
+		return t.UTC().Format(sqliteTimeFormat)
+	}
+	return value
+}
+
+func init() {
+	// SQLite has no pg_trgm; provide its similarity function so that the
+	// search queries are the same in both dialects.
+	sqlite.MustRegisterDeterministicScalarFunction("similarity", 2,
+		func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			a, _ := args[0].(string)
+			b, _ := args[1].(string)
+			return similarity(a, b), nil
+		},
+	)
+}
@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Enrichment is a cached provider answer for one attribute of a name.
+type Enrichment struct {
+	Name      string    `db:"name"`
+	Attribute string    `db:"attribute"`
+	Value     string    `db:"value"`
+	FetchedAt time.Time `db:"fetched_at"`
+}
+
+type EnrichmentRepository interface {
+	// GetEnrichment returns ErrNotFound if nothing is stored for the
+	// attribute of name.
+	GetEnrichment(ctx context.Context, name, attribute string) (*Enrichment, error)
+	// SaveEnrichment stores e, replacing an older answer, and sets its
+	// FetchedAt.
+	SaveEnrichment(ctx context.Context, e *Enrichment) error
+}
+
+type enrichmentRepository struct {
+	db      *sqlx.DB
+	dialect Dialect
+}
+
+func NewEnrichmentRepository(db *sqlx.DB) EnrichmentRepository {
+	return &enrichmentRepository{
+		db:      db,
+		dialect: Postgres,
+	}
+}
+
+func NewSQLiteEnrichmentRepository(db *sqlx.DB) EnrichmentRepository {
+	return &enrichmentRepository{
+		db:      db,
+		dialect: SQLite,
+	}
+}
+
+func (rp *enrichmentRepository) GetEnrichment(ctx context.Context, name, attribute string) (*Enrichment, error) {
+	query := `
+		SELECT name, attribute, value, fetched_at
+		FROM name_enrichment
+		WHERE name=$1 AND attribute=$2`
+
+	var e Enrichment
+	err := rp.db.GetContext(ctx, &e, query, name, attribute)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch enrichment: %w", translateError(err))
+	}
+
+	return &e, nil
+}
+
+func (rp *enrichmentRepository) SaveEnrichment(ctx context.Context, e *Enrichment) error {
+	query := `
+		INSERT INTO name_enrichment(name, attribute, value)
+		VALUES($1, $2, $3)
+		ON CONFLICT (name, attribute)
+		DO UPDATE SET value = excluded.value, fetched_at = ` + rp.dialect.now() + `
+		RETURNING fetched_at`
+
+	err := rp.db.GetContext(ctx, &e.FetchedAt, query, e.Name, e.Attribute, e.Value)
+	if err != nil {
+		return fmt.Errorf("failed to save enrichment: %w", err)
+	}
+
+	return nil
+}
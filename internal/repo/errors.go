@@ -0,0 +1,40 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist, e.g.
+	// no live person has the requested id.
+	ErrNotFound = errors.New("person not found")
+	// ErrConflict is returned when a write would give two live people the
+	// same name and surname.
+	ErrConflict = errors.New("person with this name and surname already exists")
+)
+
+// pgUniqueViolation is the SQLSTATE of a unique constraint violation.
+const pgUniqueViolation = "23505"
+
+// translateError replaces the driver errors callers need to tell apart
+// with ErrNotFound and ErrConflict and returns other errors unchanged.
+func translateError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return ErrConflict
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return ErrConflict
+	}
+	return err
+}
@@ -0,0 +1,158 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PersonChanges holds the fields a bulk update sets. Zero values are left
+// unchanged.
+type PersonChanges struct {
+	Name        string
+	Surname     string
+	Age         int
+	Gender      string
+	Nationality string
+}
+
+func (c *PersonChanges) IsEmpty() bool {
+	return *c == PersonChanges{}
+}
+
+// selectForUpdate locks and returns the people matching the predicates of
+// filter, ignoring its sorting and pagination.
+func (rp *personRepository) selectForUpdate(ctx context.Context, tx *sqlx.Tx, filter *Filter) ([]*Person, error) {
+	query := `
+		SELECT ` + selectColumns(nil) + `
+		FROM people`
+	clause, args := filter.BuildWhere(rp.dialect)
+	query += clause + rp.dialect.forUpdate()
+
+	var people []*Person
+	if err := tx.SelectContext(ctx, &people, query, args...); err != nil {
+		return nil, err
+	}
+	return people, nil
+}
+
+func ids(people []*Person) []int64 {
+	ids := make([]int64, len(people))
+	for i, p := range people {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+// UpdateByFilters applies changes to every non deleted person matching
+// filter with a single UPDATE in one transaction, and returns how many
+// people were updated.
+func (rp *personRepository) UpdateByFilters(ctx context.Context, filter *Filter, changes *PersonChanges) (int64, error) {
+	b := builder{dialect: rp.dialect}
+	var set []string
+	add := func(column string, value any) {
+		set = append(set, column+" = "+b.bind(value))
+	}
+	if changes.Name != "" {
+		add("name", changes.Name)
+	}
+	if changes.Surname != "" {
+		add("surname", changes.Surname)
+	}
+	if changes.Age != 0 {
+		add("age", changes.Age)
+	}
+	if changes.Gender != "" {
+		add("gender", changes.Gender)
+	}
+	if changes.Nationality != "" {
+		add("nationality", changes.Nationality)
+	}
+	query := `
+		UPDATE people
+		SET ` + strings.Join(set, ", ") + `, updated_at = ` + rp.dialect.now() + `, version = version + 1`
+
+	bulk := *filter
+	bulk.IncludeDeleted = false
+
+	var n int64
+	err := rp.inTx(ctx, func(tx *sqlx.Tx) error {
+		before, err := rp.selectForUpdate(ctx, tx, &bulk)
+		if err != nil {
+			return fmt.Errorf("failed to update people by filters: %w", translateError(err))
+		}
+		if len(before) == 0 {
+			return nil
+		}
+
+		b.AddWhereIn("id", ids(before))
+		query += b.String() + "RETURNING " + selectColumns(nil)
+
+		var after []*Person
+		if err := tx.SelectContext(ctx, &after, query, b.args...); err != nil {
+			return fmt.Errorf("failed to update people by filters: %w", translateError(err))
+		}
+
+		n = int64(len(after))
+		return insertBulkHistory(ctx, tx, ActionUpdate, before, after)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// DeleteByFilters soft deletes every person matching filter in one
+// transaction and returns how many people were deleted.
+func (rp *personRepository) DeleteByFilters(ctx context.Context, filter *Filter) (int64, error) {
+	query := `
+		UPDATE people SET deleted_at = ` + rp.dialect.now() + `, version = version + 1`
+
+	bulk := *filter
+	bulk.IncludeDeleted = false
+
+	var n int64
+	err := rp.inTx(ctx, func(tx *sqlx.Tx) error {
+		before, err := rp.selectForUpdate(ctx, tx, &bulk)
+		if err != nil {
+			return fmt.Errorf("failed to delete people by filters: %w", err)
+		}
+		if len(before) == 0 {
+			return nil
+		}
+
+		b := builder{dialect: rp.dialect}
+		b.AddWhereIn("id", ids(before))
+		query += b.String() + "RETURNING " + selectColumns(nil)
+
+		var after []*Person
+		if err := tx.SelectContext(ctx, &after, query, b.args...); err != nil {
+			return fmt.Errorf("failed to delete people by filters: %w", err)
+		}
+
+		n = int64(len(after))
+		return insertBulkHistory(ctx, tx, ActionDelete, before, after)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// insertBulkHistory records a history entry for every person in after,
+// paired with its state in before by id.
+func insertBulkHistory(ctx context.Context, tx *sqlx.Tx, action string, before, after []*Person) error {
+	byID := make(map[int64]*Person, len(before))
+	for _, p := range before {
+		byID[p.ID] = p
+	}
+
+	for _, p := range after {
+		if err := insertHistory(ctx, tx, p.ID, action, byID[p.ID], p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
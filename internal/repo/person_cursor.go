@@ -0,0 +1,134 @@
+package repo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCursorLimit is the page size used in cursor mode when the
+// request does not set a limit.
+const DefaultCursorLimit = 100
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor marks a position in a sorted people listing. It records the sort
+// the listing was made with and the sort key values of the last returned
+// row, so the next page starts strictly after that row.
+type cursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+}
+
+func sortString(sort []SortField) string {
+	terms := make([]string, len(sort))
+	for i, field := range sort {
+		terms[i] = field.Column
+		if field.Desc {
+			terms[i] = "-" + field.Column
+		}
+	}
+	return strings.Join(terms, ",")
+}
+
+func encodeCursor(sort []SortField, p *Person) string {
+	c := cursor{
+		Sort:   sortString(sort),
+		Values: make([]string, len(sort)),
+	}
+	for i, field := range sort {
+		c.Values[i] = columnString(field.Column, p)
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor returns the typed sort key values stored in s. The cursor
+// must have been issued for the same sort order.
+func decodeCursor(s string, sort []SortField) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != sortString(sort) || len(c.Values) != len(sort) {
+		return nil, fmt.Errorf("%w: issued for a different sort order", ErrInvalidCursor)
+	}
+
+	values := make([]any, len(sort))
+	for i, field := range sort {
+		values[i], err = parseColumnValue(field.Column, c.Values[i])
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return values, nil
+}
+
+// sortValues returns the sort key values of p, as decodeCursor would.
+func sortValues(sort []SortField, p *Person) []any {
+	values := make([]any, len(sort))
+	for i, field := range sort {
+		switch field.Column {
+		case "id":
+			values[i] = p.ID
+		case "age":
+			values[i] = p.Age
+		case "created_at":
+			values[i] = p.CreatedAt
+		case "updated_at":
+			values[i] = p.UpdatedAt
+		default:
+			values[i] = columnString(field.Column, p)
+		}
+	}
+	return values
+}
+
+func columnString(column string, p *Person) string {
+	switch column {
+	case "id":
+		return strconv.FormatInt(p.ID, 10)
+	case "name":
+		return p.Name
+	case "surname":
+		return p.Surname
+	case "age":
+		return strconv.Itoa(p.Age)
+	case "gender":
+		return p.Gender
+	case "nationality":
+		return p.Nationality
+	case "created_at":
+		return p.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		return p.UpdatedAt.Format(time.RFC3339Nano)
+	}
+	panic(fmt.Sprintf("unknown column %q", column))
+}
+
+func parseColumnValue(column, s string) (any, error) {
+	switch column {
+	case "id":
+		return strconv.ParseInt(s, 10, 64)
+	case "age":
+		return strconv.Atoi(s)
+	case "created_at", "updated_at":
+		return time.Parse(time.RFC3339Nano, s)
+	case "name", "surname", "gender", "nationality":
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown column %q", column)
+}
@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/TheTeemka/TaskNameManager/pkg/validator"
+)
+
+type personField struct {
+	JSON   string
+	Column string
+	index  int
+}
+
+// personFields lists the fields of Person by their JSON name and column,
+// in declaration order. It is the allow-list for sparse fieldsets.
+var personFields = func() []personField {
+	var fields []personField
+	t := reflect.TypeOf(Person{})
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "" || name == "-" || sf.Tag.Get("db") == "" {
+			continue
+		}
+		fields = append(fields, personField{JSON: name, Column: sf.Tag.Get("db"), index: i})
+	}
+	return fields
+}()
+
+// ParseFields parses a comma separated list of Person JSON field names.
+// It returns nil, meaning every field, when s is empty.
+func ParseFields(s string, v *validator.Validator) []string {
+	if s == "" {
+		return nil
+	}
+
+	var fields []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if !hasPersonField(name) {
+			v.AddError("fields", fmt.Sprintf("unknown field %q", name))
+			return nil
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// PersonFieldNames returns the JSON names of all Person fields in
+// declaration order.
+func PersonFieldNames() []string {
+	names := make([]string, len(personFields))
+	for i, f := range personFields {
+		names[i] = f.JSON
+	}
+	return names
+}
+
+func hasPersonField(name string) bool {
+	return slices.ContainsFunc(personFields, func(f personField) bool {
+		return f.JSON == name
+	})
+}
+
+// selectColumns returns the SELECT column list for the given JSON fields
+// plus any extra columns the query needs, in declaration order. A nil
+// fields list selects every column.
+func selectColumns(fields []string, extra ...string) string {
+	var columns []string
+	for _, f := range personFields {
+		if fields == nil || slices.Contains(fields, f.JSON) || slices.Contains(extra, f.Column) {
+			columns = append(columns, f.Column)
+		}
+	}
+	return strings.Join(columns, ", ")
+}
+
+// Project returns p as a map holding only the given JSON fields, for
+// encoding sparse fieldsets.
+func (p *Person) Project(fields []string) map[string]any {
+	rv := reflect.ValueOf(p).Elem()
+	m := make(map[string]any, len(fields))
+	for _, f := range personFields {
+		if slices.Contains(fields, f.JSON) {
+			m[f.JSON] = rv.Field(f.index).Interface()
+		}
+	}
+	return m
+}
@@ -2,22 +2,67 @@ package repo
 
 import (
 	"fmt"
+	"math"
 	"net/url"
+	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/TheTeemka/TaskNameManager/pkg/validator"
 )
 
+// filterColumns is the allow-list of columns a Filter may put into a
+// WHERE clause. Column names are never taken from user input directly.
+var filterColumns = map[string]bool{
+	"id":          true,
+	"name":        true,
+	"surname":     true,
+	"age":         true,
+	"gender":      true,
+	"nationality": true,
+	"created_at":  true,
+	"updated_at":  true,
+}
+
+// SortField is a single ORDER BY term.
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
 type Filter struct {
-	Name        string
-	Surname     string
-	Age         string
-	Gender      string
-	Nationality string
-	// CreatedAt   time.Time
-	// UpdatedAt   time.Time
-	limit  string
-	offset string
+	// Query is a free text search over name and surname. Unless an
+	// explicit sort is given, results are ranked by similarity to it.
+	Query   string
+	Name    string
+	Surname string
+	Age     string
+	AgeMin  string
+	AgeMax  string
+	// IDs, Gender and Nationality match any of their values.
+	IDs         []int64
+	Gender      []string
+	Nationality []string
+	// Time bounds are inclusive for *After and exclusive for *Before.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	// IncludeDeleted also lists soft deleted people.
+	IncludeDeleted bool
+	// Fields lists the JSON fields to select; nil selects all of them.
+	Fields []string
+	// Sort always ends with an id term so that ordering is stable.
+	Sort []SortField
+	// rankByQuery orders the listing by relevance to Query before Sort.
+	rankByQuery bool
+	// useCursor switches the listing from offset to keyset pagination;
+	// after holds the sort key values of the row the page starts after.
+	useCursor bool
+	after     []any
+	limit     string
+	offset    string
 }
 
 func NewFilters() *Filter {
@@ -25,84 +70,492 @@ func NewFilters() *Filter {
 }
 
 func (f *Filter) ParseURL(vals url.Values, v *validator.Validator) {
+	f.Query = strings.TrimSpace(vals.Get("q"))
+	if f.Query != "" {
+		v.CheckWithRules("q", f.Query, validator.IsValidLength(1, 40))
+	}
+
 	f.Name = vals.Get("name")
 	f.Surname = vals.Get("surname")
-	f.Gender = vals.Get("gender")
-	f.Nationality = vals.Get("nationality")
+
+	for _, id := range parseList(vals, "id", v, validator.IsInt(64)) {
+		n, _ := strconv.ParseInt(id, 10, 64)
+		f.IDs = append(f.IDs, n)
+	}
+	f.Gender = parseList(vals, "gender", v, validator.IsValidLength(1, 10))
+	f.Nationality = parseList(vals, "nationality", v, validator.IsValidLength(1, 10))
 
 	f.Age = vals.Get("age")
 	if f.Age != "" {
-		v.CheckWithRules("age", f.Age, validator.IsInt(0))
+		v.CheckWithRules("age", f.Age, validator.IsIntBetween(0, math.MaxInt32))
+	}
+
+	f.AgeMin = vals.Get("age_min")
+	if f.AgeMin != "" {
+		v.CheckWithRules("age_min", f.AgeMin, validator.IsIntBetween(0, math.MaxInt32))
+	}
+
+	f.AgeMax = vals.Get("age_max")
+	if f.AgeMax != "" {
+		v.CheckWithRules("age_max", f.AgeMax, validator.IsIntBetween(0, math.MaxInt32))
+	}
+
+	if f.AgeMin != "" && f.AgeMax != "" && v.Valid() {
+		ageMin, _ := strconv.Atoi(f.AgeMin)
+		ageMax, _ := strconv.Atoi(f.AgeMax)
+		v.Check(ageMin <= ageMax, "age_min", "must not be greater than age_max")
+	}
+
+	f.CreatedAfter = parseTime(vals, "created_after", v)
+	f.CreatedBefore = parseTime(vals, "created_before", v)
+	f.UpdatedAfter = parseTime(vals, "updated_after", v)
+	f.UpdatedBefore = parseTime(vals, "updated_before", v)
+	checkWindow(f.CreatedAfter, f.CreatedBefore, "created_after", "created_before", v)
+	checkWindow(f.UpdatedAfter, f.UpdatedBefore, "updated_after", "updated_before", v)
+
+	if s := vals.Get("include_deleted"); s != "" {
+		var err error
+		f.IncludeDeleted, err = strconv.ParseBool(s)
+		v.Check(err == nil, "include_deleted", "must be a boolean")
 	}
 
+	f.Fields = ParseFields(vals.Get("fields"), v)
+	f.Sort = parseSort(vals.Get("sort"), v)
+	f.rankByQuery = f.Query != "" && vals.Get("sort") == ""
+
 	f.limit = vals.Get("limit")
 	if f.limit != "" {
-		v.CheckWithRules("limit", f.limit, validator.IsInt(0))
+		v.CheckWithRules("limit", f.limit, validator.IsIntBetween(0, math.MaxInt32))
 	}
 
 	f.offset = vals.Get("offset")
 	if f.offset != "" {
-		v.CheckWithRules("offset", f.offset, validator.IsInt(0))
+		v.CheckWithRules("offset", f.offset, validator.IsIntBetween(0, math.MaxInt32))
+	}
+
+	f.useCursor = vals.Has("cursor")
+	if f.useCursor {
+		if f.offset != "" {
+			v.AddError("cursor", "cannot be combined with offset")
+		}
+		if f.rankByQuery {
+			v.AddError("cursor", "requires an explicit sort when q is given")
+		}
+		if f.limit == "" {
+			f.limit = strconv.Itoa(DefaultCursorLimit)
+		}
+
+		if s := vals.Get("cursor"); s != "" && v.Valid() {
+			after, err := decodeCursor(s, f.Sort)
+			if err != nil {
+				v.AddError("cursor", err.Error())
+			}
+			f.after = after
+		}
 	}
 }
 
-func (f *Filter) String() string {
-	var builder builder
-	if f.Name != "" {
-		builder.AddWhere("name", f.Name)
+// UsesCursor reports whether the listing is paginated with a cursor
+// rather than an offset.
+func (f *Filter) UsesCursor() bool {
+	return f.useCursor
+}
+
+// Limit returns the requested page size, or 0 if the listing is unbounded.
+func (f *Filter) Limit() int {
+	limit, _ := strconv.Atoi(f.limit)
+	return limit
+}
+
+// Offset returns the number of rows skipped in offset mode.
+func (f *Filter) Offset() int {
+	offset, _ := strconv.Atoi(f.offset)
+	return offset
+}
+
+// NextCursor returns the cursor for the page following people, or an
+// empty string if people is the last page.
+func (f *Filter) NextCursor(people []*Person) string {
+	if !f.useCursor || len(people) == 0 || len(people) < f.Limit() {
+		return ""
 	}
-	if f.Surname != "" {
-		builder.AddWhere("surname", f.Surname)
+	return encodeCursor(f.Sort, people[len(people)-1])
+}
+
+// maxListValues caps the number of values a multi-value parameter may hold.
+const maxListValues = 100
+
+// parseList collects the values of a parameter that may be repeated or
+// comma separated, e.g. "nationality=RU,KZ&nationality=UA". Each value is
+// checked with rules and reported under its index, e.g. "id[2]". A
+// parameter with only empty values, e.g. "gender=", sets no filter.
+func parseList(vals url.Values, key string, v *validator.Validator, rules ...validator.StringRule) []string {
+	var list []string
+	empty := true
+	for _, val := range vals[key] {
+		for _, item := range strings.Split(val, ",") {
+			item = strings.TrimSpace(item)
+			empty = empty && item == ""
+			list = append(list, item)
+		}
 	}
-	if f.Age != "" {
-		builder.AddWhere("age", f.Age)
+	if empty {
+		return nil
+	}
+
+	if len(list) > maxListValues {
+		v.AddError(key, fmt.Sprintf("must not contain more than %d values", maxListValues))
+		return nil
+	}
+	for i, item := range list {
+		v.CheckWithRules(fmt.Sprintf("%s[%d]", key, i), item, rules...)
+	}
+	return list
+}
+
+func parseTime(vals url.Values, key string, v *validator.Validator) time.Time {
+	s := vals.Get(key)
+	if s == "" {
+		return time.Time{}
+	}
+
+	v.CheckWithRules(key, s, validator.IsDateTime)
+	t, _ := validator.ParseDateTime(s)
+	return t
+}
+
+// checkWindow reports a time window whose lower bound is not before its
+// upper bound; such a window matches nothing.
+func checkWindow(after, before time.Time, afterKey, beforeKey string, v *validator.Validator) {
+	if !after.IsZero() && !before.IsZero() {
+		v.Check(after.Before(before), afterKey, "must be earlier than "+beforeKey)
 	}
-	if f.Gender != "" {
-		builder.AddWhere("gender", f.Gender)
+}
+
+// parseSort parses a comma separated list of columns, each optionally
+// prefixed with "-" for descending order, e.g. "surname,-age".
+func parseSort(s string, v *validator.Validator) []SortField {
+	var sort []SortField
+	seen := map[string]bool{}
+	if s != "" {
+		for _, term := range strings.Split(s, ",") {
+			field := SortField{Column: strings.TrimSpace(term)}
+			if strings.HasPrefix(field.Column, "-") {
+				field.Column = field.Column[1:]
+				field.Desc = true
+			}
+
+			if !filterColumns[field.Column] {
+				v.AddError("sort", fmt.Sprintf("unknown sort column %q", field.Column))
+				return nil
+			}
+			if seen[field.Column] {
+				v.AddError("sort", fmt.Sprintf("duplicate sort column %q", field.Column))
+				return nil
+			}
+			seen[field.Column] = true
+			sort = append(sort, field)
+		}
 	}
-	if f.Nationality != "" {
-		builder.AddWhere("nationality", f.Nationality)
+
+	if !seen["id"] {
+		sort = append(sort, SortField{Column: "id"})
+	}
+	return sort
+}
+
+// Columns returns the SELECT column list for the filter. It always
+// includes the sort columns so that cursors can be built from the rows.
+func (f *Filter) Columns() string {
+	sortColumns := make([]string, len(f.Sort))
+	for i, field := range f.Sort {
+		sortColumns[i] = field.Column
+	}
+	return selectColumns(f.Fields, sortColumns...)
+}
+
+// Build returns the SQL suffix (WHERE, ORDER BY, LIMIT, OFFSET) for the filter
+// in dialect d together with the arguments bound to its $1..$n placeholders.
+func (f *Filter) Build(d Dialect) (string, []any) {
+	builder := builder{dialect: d}
+	f.addPredicates(&builder)
+
+	if f.after != nil {
+		builder.AddKeyset(f.Sort, f.after)
+	}
+
+	if f.rankByQuery {
+		builder.AddOrderBySimilarity(f.Query)
+	}
+	for _, field := range f.Sort {
+		builder.AddOrderBy(field.Column, field.Desc)
 	}
 
 	if f.limit != "" {
-		builder.AddLimit(f.limit)
+		builder.AddLimit(f.Limit())
 	}
 	if f.offset != "" {
-		builder.AddOffset(f.offset)
+		builder.AddOffset(f.Offset())
+	}
+
+	return builder.String(), builder.args
+}
+
+// BuildWhere returns only the WHERE clause of the filter, ignoring sorting
+// and pagination, so that it can be shared by COUNT queries.
+func (f *Filter) BuildWhere(d Dialect) (string, []any) {
+	builder := builder{dialect: d}
+	f.addPredicates(&builder)
+	return builder.String(), builder.args
+}
+
+// HasPredicates reports whether the filter restricts the people it
+// matches, beyond excluding soft deleted ones.
+func (f *Filter) HasPredicates() bool {
+	// Every predicate other than the soft delete one binds an argument.
+	_, args := f.BuildWhere(Postgres)
+	return len(args) > 0
+}
+
+func (f *Filter) addPredicates(builder *builder) {
+	if !f.IncludeDeleted {
+		builder.AddNotDeleted()
+	}
+	if f.Query != "" {
+		builder.AddSearch(f.Query)
+	}
+	if f.Name != "" {
+		builder.AddWhere("name", "=", f.Name)
+	}
+	if f.Surname != "" {
+		builder.AddWhere("surname", "=", f.Surname)
+	}
+	if f.Age != "" {
+		age, _ := strconv.Atoi(f.Age)
+		builder.AddWhere("age", "=", age)
+	}
+	if f.AgeMin != "" {
+		ageMin, _ := strconv.Atoi(f.AgeMin)
+		builder.AddWhere("age", ">=", ageMin)
+	}
+	if f.AgeMax != "" {
+		ageMax, _ := strconv.Atoi(f.AgeMax)
+		builder.AddWhere("age", "<=", ageMax)
+	}
+	if len(f.IDs) > 0 {
+		builder.AddWhereIn("id", f.IDs)
+	}
+	if len(f.Gender) > 0 {
+		builder.AddWhereIn("gender", f.Gender)
+	}
+	if len(f.Nationality) > 0 {
+		builder.AddWhereIn("nationality", f.Nationality)
+	}
+	if !f.CreatedAfter.IsZero() {
+		builder.AddWhere("created_at", ">=", f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		builder.AddWhere("created_at", "<", f.CreatedBefore)
+	}
+	if !f.UpdatedAfter.IsZero() {
+		builder.AddWhere("updated_at", ">=", f.UpdatedAfter)
+	}
+	if !f.UpdatedBefore.IsZero() {
+		builder.AddWhere("updated_at", "<", f.UpdatedBefore)
 	}
 
-	return builder.String()
 }
 
 type builder struct {
-	where  strings.Builder
-	limit  strings.Builder
-	offset strings.Builder
+	dialect Dialect
+	where   strings.Builder
+	orderBy strings.Builder
+	limit   strings.Builder
+	offset  strings.Builder
+	args    []any
+}
+
+// bind appends value to the argument list and returns its placeholder.
+func (b *builder) bind(value any) string {
+	b.args = append(b.args, b.dialect.arg(value))
+	return "$" + strconv.Itoa(len(b.args))
 }
 
-func (b *builder) AddWhere(key, value string) {
+// addPredicate ANDs pred into the WHERE clause.
+func (b *builder) addPredicate(pred string) {
 	if b.where.Len() == 0 {
 		b.where.WriteString("WHERE ")
 	} else {
 		b.where.WriteString("AND ")
 	}
-	b.where.WriteString(fmt.Sprintf("%s = '%s' ", key, value))
+	b.where.WriteString(pred + " ")
+}
+
+// whereOperators lists the comparison operators AddWhere accepts.
+var whereOperators = map[string]bool{
+	"=":  true,
+	"<":  true,
+	"<=": true,
+	">":  true,
+	">=": true,
+}
+
+func (b *builder) AddWhere(key, op string, value any) {
+	if !filterColumns[key] {
+		panic(fmt.Sprintf("column %q is not allowed in filters", key))
+	}
+	if !whereOperators[op] {
+		panic(fmt.Sprintf("operator %q is not allowed in filters", op))
+	}
+
+	b.addPredicate(fmt.Sprintf("%s %s %s", key, op, b.bind(value)))
 }
 
-func (b *builder) AddLimit(value string) {
+// AddKeyset restricts the rows to those sorting strictly after values,
+// expanded as (c1 > v1) OR (c1 = v1 AND c2 > v2) OR ... so that mixed
+// sort directions are supported.
+func (b *builder) AddKeyset(sort []SortField, values []any) {
+	placeholders := make([]string, len(values))
+	for i, value := range values {
+		placeholders[i] = b.bind(value)
+	}
+
+	terms := make([]string, len(sort))
+	for i, field := range sort {
+		if !filterColumns[field.Column] {
+			panic(fmt.Sprintf("column %q is not allowed in filters", field.Column))
+		}
+
+		var term strings.Builder
+		for j := range i {
+			term.WriteString(fmt.Sprintf("%s = %s AND ", sort[j].Column, placeholders[j]))
+		}
+		op := ">"
+		if field.Desc {
+			op = "<"
+		}
+		term.WriteString(fmt.Sprintf("%s %s %s", field.Column, op, placeholders[i]))
+		terms[i] = "(" + term.String() + ")"
+	}
+
+	b.addPredicate("(" + strings.Join(terms, " OR ") + ")")
+}
+
+// AddNotDeleted excludes soft deleted rows.
+func (b *builder) AddNotDeleted() {
+	b.addPredicate("deleted_at IS NULL")
+}
+
+// AddWhereIn matches rows whose column equals any of values, which must be
+// a slice. A single value is compared with a plain "=".
+func (b *builder) AddWhereIn(key string, values any) {
+	rv := reflect.ValueOf(values)
+	if rv.Kind() != reflect.Slice {
+		panic("AddWhereIn expects a slice of values")
+	}
+	if rv.Len() == 1 {
+		b.AddWhere(key, "=", rv.Index(0).Interface())
+		return
+	}
+
+	if !filterColumns[key] {
+		panic(fmt.Sprintf("column %q is not allowed in filters", key))
+	}
+
+	if b.dialect == SQLite {
+		// SQLite has no arrays; bind every value on its own.
+		placeholders := make([]string, rv.Len())
+		for i := range placeholders {
+			placeholders[i] = b.bind(rv.Index(i).Interface())
+		}
+		b.addPredicate(fmt.Sprintf("%s IN (%s)", key, strings.Join(placeholders, ", ")))
+		return
+	}
+	b.addPredicate(fmt.Sprintf("%s = ANY(%s)", key, b.bind(values)))
+}
+
+// likeEscaper escapes the LIKE wildcards so that user input only ever
+// matches literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// AddSearch matches people whose name or surname starts with term, case
+// insensitively, or is trigram-similar to it (requires pg_trgm).
+func (b *builder) AddSearch(term string) {
+	prefix := b.bind(likeEscaper.Replace(term) + "%")
+	similar := b.bind(term)
+
+	if b.dialect == SQLite {
+		// SQLite's LIKE is case insensitive but has no default escape.
+		b.addPredicate(fmt.Sprintf(
+			`(name LIKE %[1]s ESCAPE '\' OR surname LIKE %[1]s ESCAPE '\' `+
+				`OR similarity(name, %[2]s) >= %[3]g OR similarity(surname, %[2]s) >= %[3]g)`,
+			prefix, similar, similarityThreshold,
+		))
+		return
+	}
+	b.addPredicate(fmt.Sprintf(
+		"(name ILIKE %[1]s OR surname ILIKE %[1]s OR name %% %[2]s OR surname %% %[2]s)",
+		prefix, similar,
+	))
+}
+
+// AddOrderBySimilarity ranks rows by how similar name or surname is to term.
+func (b *builder) AddOrderBySimilarity(term string) {
+	if b.orderBy.Len() == 0 {
+		b.orderBy.WriteString("ORDER BY ")
+	} else {
+		b.orderBy.WriteString(", ")
+	}
+	greatest := "GREATEST"
+	if b.dialect == SQLite {
+		greatest = "MAX"
+	}
+	similar := b.bind(term)
+	b.orderBy.WriteString(fmt.Sprintf(
+		"%[1]s(similarity(name, %[2]s), similarity(surname, %[2]s)) DESC", greatest, similar,
+	))
+}
+
+func (b *builder) AddOrderBy(key string, desc bool) {
+	if !filterColumns[key] {
+		panic(fmt.Sprintf("column %q is not allowed in sorting", key))
+	}
+
+	if b.orderBy.Len() == 0 {
+		b.orderBy.WriteString("ORDER BY ")
+	} else {
+		b.orderBy.WriteString(", ")
+	}
+	b.orderBy.WriteString(key)
+	if desc {
+		b.orderBy.WriteString(" DESC")
+	}
+}
+
+func (b *builder) AddLimit(value int) {
 	if b.limit.Len() > 0 {
 		panic("Only one limit can be added")
 	}
-	b.limit.WriteString("LIMIT " + value + " ")
+	b.limit.WriteString("LIMIT " + b.bind(value) + " ")
 }
 
-func (b *builder) AddOffset(value string) {
+func (b *builder) AddOffset(value int) {
 	if b.offset.Len() > 0 {
 		panic("Only one offset can be added")
 	}
-	b.limit.WriteString("OFFSET " + value + " ")
+	b.offset.WriteString("OFFSET " + b.bind(value) + " ")
 }
 
 func (b *builder) String() string {
-	return " " + b.where.String() + b.limit.String() + b.offset.String()
+	orderBy := b.orderBy.String()
+	if orderBy != "" {
+		orderBy += " "
+	}
+	limit := b.limit.String()
+	if limit == "" && b.offset.Len() > 0 && b.dialect == SQLite {
+		// SQLite only accepts OFFSET after a LIMIT; a negative one is
+		// unbounded.
+		limit = "LIMIT -1 "
+	}
+	return " " + b.where.String() + orderBy + limit + b.offset.String()
 }
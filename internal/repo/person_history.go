@@ -0,0 +1,165 @@
+package repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+)
+
+// PersonHistory is one recorded change of a person.
+type PersonHistory struct {
+	ID            int64      `db:"id" json:"id"`
+	PersonID      int64      `db:"person_id" json:"person_id"`
+	Action        string     `db:"action" json:"action"`
+	Actor         string     `db:"actor" json:"actor"`
+	Before        Snapshot   `db:"before" json:"before" swaggertype:"object"`
+	After         Snapshot   `db:"after" json:"after" swaggertype:"object"`
+	ChangedFields StringList `db:"changed_fields" json:"changed_fields" swaggertype:"array,string"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+}
+
+// Snapshot is a nullable copy of a Person stored as JSON.
+type Snapshot struct {
+	Person *Person
+}
+
+func (s *Snapshot) Scan(src any) error {
+	s.Person = nil
+	if src == nil {
+		return nil
+	}
+	s.Person = new(Person)
+	return scanJSON(src, s.Person)
+}
+
+func (s Snapshot) Value() (driver.Value, error) {
+	if s.Person == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(s.Person)
+	return string(b), err
+}
+
+func (s Snapshot) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Person)
+}
+
+// StringList is a list of strings stored as a JSON array.
+type StringList []string
+
+func (l *StringList) Scan(src any) error {
+	return scanJSON(src, (*[]string)(l))
+}
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		l = StringList{}
+	}
+	b, err := json.Marshal([]string(l))
+	return string(b), err
+}
+
+func scanJSON(src any, v any) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, v)
+	case string:
+		return json.Unmarshal([]byte(src), v)
+	}
+	return fmt.Errorf("cannot scan %T as JSON", src)
+}
+
+type actorKey struct{}
+
+// WithActor returns a context that attributes the changes made with it to
+// actor in the people history.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func actorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// changedFields returns the JSON names of the fields that differ between
+// before and after, ignoring updated_at. A nil side counts as every field
+// being changed.
+func changedFields(before, after *Person) []string {
+	changed := []string{}
+	for _, f := range personFields {
+		if f.Column == "updated_at" {
+			continue
+		}
+		if before == nil || after == nil {
+			changed = append(changed, f.JSON)
+			continue
+		}
+		b := reflect.ValueOf(before).Elem().Field(f.index).Interface()
+		a := reflect.ValueOf(after).Elem().Field(f.index).Interface()
+		if !reflect.DeepEqual(b, a) {
+			changed = append(changed, f.JSON)
+		}
+	}
+	return changed
+}
+
+func insertHistory(ctx context.Context, tx *sqlx.Tx, personID int64, action string, before, after *Person) error {
+	query := `
+		INSERT INTO people_history(person_id, action, actor, before, after, changed_fields)
+		VALUES($1, $2, $3, $4, $5, $6)`
+
+	_, err := tx.ExecContext(ctx, query,
+		personID, action, actorFrom(ctx),
+		Snapshot{Person: before}, Snapshot{Person: after},
+		StringList(changedFields(before, after)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record person history: %w", err)
+	}
+	return nil
+}
+
+func (rp *personRepository) GetHistory(ctx context.Context, personID int64, limit, offset int) ([]*PersonHistory, error) {
+	query := `
+		SELECT id, person_id, action, actor, before, after, changed_fields, created_at
+		FROM people_history
+		WHERE person_id=$1
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3`
+
+	var h []*PersonHistory
+	err := rp.conn(ctx).SelectContext(ctx, &h, query, personID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch person history: %w", err)
+	}
+
+	return h, nil
+}
+
+func (rp *personRepository) CountHistory(ctx context.Context, personID int64) (int64, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM people_history
+		WHERE person_id=$1`
+
+	var count int64
+	err := rp.conn(ctx).GetContext(ctx, &count, query, personID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count person history: %w", err)
+	}
+
+	return count, nil
+}
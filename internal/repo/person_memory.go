@@ -0,0 +1,561 @@
+package repo
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryRepository keeps people in memory. It follows the semantics of the
+// Postgres repository and is meant for tests and demo instances.
+type memoryRepository struct {
+	mu      sync.RWMutex
+	people  map[int64]*Person
+	history []*PersonHistory
+	lastID  int64
+	lastHID int64
+}
+
+func NewMemoryPersonRepository() PersonRepository {
+	return &memoryRepository{
+		people: map[int64]*Person{},
+	}
+}
+
+type memoryTxKey struct{}
+
+func (rp *memoryRepository) inTx(ctx context.Context) bool {
+	tx, _ := ctx.Value(memoryTxKey{}).(*memoryRepository)
+	return tx == rp
+}
+
+// lock takes the write lock unless ctx runs in a transaction, which holds
+// it already, and returns the function releasing it.
+func (rp *memoryRepository) lock(ctx context.Context) func() {
+	if rp.inTx(ctx) {
+		return func() {}
+	}
+	rp.mu.Lock()
+	return rp.mu.Unlock
+}
+
+func (rp *memoryRepository) rlock(ctx context.Context) func() {
+	if rp.inTx(ctx) {
+		return func() {}
+	}
+	rp.mu.RLock()
+	return rp.mu.RUnlock
+}
+
+// InTx runs fn holding the write lock, so transactions are serialized,
+// and restores the state taken before fn if it fails.
+func (rp *memoryRepository) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if rp.inTx(ctx) {
+		return fn(ctx)
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	people := make(map[int64]*Person, len(rp.people))
+	for id, p := range rp.people {
+		people[id] = clonePerson(p)
+	}
+	history, lastID, lastHID := slices.Clone(rp.history), rp.lastID, rp.lastHID
+
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, rp)); err != nil {
+		rp.people, rp.history, rp.lastID, rp.lastHID = people, history, lastID, lastHID
+		return err
+	}
+	return nil
+}
+
+func clonePerson(p *Person) *Person {
+	c := *p
+	if p.DeletedAt != nil {
+		deletedAt := *p.DeletedAt
+		c.DeletedAt = &deletedAt
+	}
+	return &c
+}
+
+// conflicts reports whether a live person other than p has p's name and
+// surname.
+func (rp *memoryRepository) conflicts(p *Person) bool {
+	for _, other := range rp.people {
+		if other.ID != p.ID && other.DeletedAt == nil &&
+			other.Name == p.Name && other.Surname == p.Surname {
+			return true
+		}
+	}
+	return false
+}
+
+func (rp *memoryRepository) recordHistory(ctx context.Context, personID int64, action string, before, after *Person) {
+	rp.lastHID++
+	h := &PersonHistory{
+		ID:            rp.lastHID,
+		PersonID:      personID,
+		Action:        action,
+		Actor:         actorFrom(ctx),
+		ChangedFields: changedFields(before, after),
+		CreatedAt:     time.Now(),
+	}
+	if before != nil {
+		h.Before.Person = clonePerson(before)
+	}
+	if after != nil {
+		h.After.Person = clonePerson(after)
+	}
+	rp.history = append(rp.history, h)
+}
+
+func (rp *memoryRepository) create(ctx context.Context, person *Person) error {
+	if rp.conflicts(person) {
+		return fmt.Errorf("failed to create person: %w", ErrConflict)
+	}
+
+	rp.lastID++
+	now := time.Now()
+	person.ID, person.CreatedAt, person.UpdatedAt = rp.lastID, now, now
+	person.Version, person.DeletedAt = 1, nil
+
+	rp.people[person.ID] = clonePerson(person)
+	rp.recordHistory(ctx, person.ID, ActionCreate, nil, person)
+	return nil
+}
+
+func (rp *memoryRepository) Create(ctx context.Context, person *Person) (*Person, error) {
+	defer rp.lock(ctx)()
+
+	if err := rp.create(ctx, person); err != nil {
+		return nil, err
+	}
+	return person, nil
+}
+
+func (rp *memoryRepository) CreateMany(ctx context.Context, people []*Person) error {
+	defer rp.lock(ctx)()
+
+	seen := map[[2]string]bool{}
+	for _, p := range people {
+		key := [2]string{p.Name, p.Surname}
+		if seen[key] || rp.conflicts(p) {
+			return fmt.Errorf("failed to create person: %w", ErrConflict)
+		}
+		seen[key] = true
+	}
+
+	for _, p := range people {
+		if err := rp.create(ctx, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rp *memoryRepository) GetByNames(ctx context.Context, people []*Person) ([]*Person, error) {
+	defer rp.rlock(ctx)()
+
+	names := make(map[[2]string]bool, len(people))
+	for _, p := range people {
+		names[[2]string{p.Name, p.Surname}] = true
+	}
+
+	var found []*Person
+	for _, p := range rp.people {
+		if p.DeletedAt == nil && names[[2]string{p.Name, p.Surname}] {
+			found = append(found, clonePerson(p))
+		}
+	}
+	slices.SortFunc(found, func(a, b *Person) int { return cmp.Compare(a.ID, b.ID) })
+	return found, nil
+}
+
+// GetByIDForUpdate needs no row lock, the transaction holds the write
+// lock for its whole duration.
+func (rp *memoryRepository) GetByIDForUpdate(ctx context.Context, id int64) (*Person, error) {
+	return rp.GetByID(ctx, id)
+}
+
+func (rp *memoryRepository) GetByID(ctx context.Context, id int64, fields ...string) (*Person, error) {
+	defer rp.rlock(ctx)()
+
+	p, ok := rp.people[id]
+	if !ok || p.DeletedAt != nil {
+		return nil, fmt.Errorf("failed to fetch person by id: %w", ErrNotFound)
+	}
+	return clonePerson(p), nil
+}
+
+// find returns copies of the people matching the predicates of filter,
+// sorted and paginated as the filter asks.
+func (rp *memoryRepository) find(filter *Filter, paginate bool) []*Person {
+	var people []*Person
+	for _, p := range rp.people {
+		if filter.match(p) {
+			people = append(people, clonePerson(p))
+		}
+	}
+
+	slices.SortFunc(people, func(a, b *Person) int {
+		if filter.rankByQuery {
+			ra, rb := searchRank(a, filter.Query), searchRank(b, filter.Query)
+			if c := cmp.Compare(rb, ra); c != 0 {
+				return c
+			}
+		}
+		if c := compareSort(filter.Sort, a, b); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	if !paginate {
+		return people
+	}
+
+	if filter.after != nil {
+		people = slices.DeleteFunc(people, func(p *Person) bool {
+			return compareSortValues(filter.Sort, p, filter.after) <= 0
+		})
+	}
+	if offset := filter.Offset(); offset > 0 {
+		people = people[min(offset, len(people)):]
+	}
+	if limit := filter.Limit(); filter.limit != "" && limit < len(people) {
+		people = people[:max(limit, 0)]
+	}
+	return people
+}
+
+func (rp *memoryRepository) GetByFilters(ctx context.Context, filter *Filter) ([]*Person, error) {
+	defer rp.rlock(ctx)()
+
+	return rp.find(filter, true), nil
+}
+
+func (rp *memoryRepository) CountByFilters(ctx context.Context, filter *Filter) (int64, error) {
+	defer rp.rlock(ctx)()
+
+	return int64(len(rp.find(filter, false))), nil
+}
+
+func (rp *memoryRepository) StreamByFilters(ctx context.Context, filter *Filter, fn func(*Person) error) error {
+	people, err := rp.GetByFilters(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range people {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rp *memoryRepository) UpdateByFilters(ctx context.Context, filter *Filter, changes *PersonChanges) (int64, error) {
+	defer rp.lock(ctx)()
+
+	bulk := *filter
+	bulk.IncludeDeleted = false
+	matched := rp.find(&bulk, false)
+
+	updated := make([]*Person, len(matched))
+	for i, before := range matched {
+		p := clonePerson(before)
+		if changes.Name != "" {
+			p.Name = changes.Name
+		}
+		if changes.Surname != "" {
+			p.Surname = changes.Surname
+		}
+		if changes.Age != 0 {
+			p.Age = changes.Age
+		}
+		if changes.Gender != "" {
+			p.Gender = changes.Gender
+		}
+		if changes.Nationality != "" {
+			p.Nationality = changes.Nationality
+		}
+		p.UpdatedAt = time.Now()
+		p.Version++
+		updated[i] = p
+	}
+
+	// Check every row before writing any, as the single UPDATE would.
+	names := map[[2]string]bool{}
+	for _, p := range updated {
+		key := [2]string{p.Name, p.Surname}
+		if names[key] {
+			return 0, fmt.Errorf("failed to update people by filters: %w", ErrConflict)
+		}
+		names[key] = true
+	}
+	for _, other := range rp.people {
+		if names[[2]string{other.Name, other.Surname}] && other.DeletedAt == nil && !slices.ContainsFunc(updated, func(p *Person) bool { return p.ID == other.ID }) {
+			return 0, fmt.Errorf("failed to update people by filters: %w", ErrConflict)
+		}
+	}
+
+	for i, p := range updated {
+		rp.people[p.ID] = clonePerson(p)
+		rp.recordHistory(ctx, p.ID, ActionUpdate, matched[i], p)
+	}
+	return int64(len(updated)), nil
+}
+
+func (rp *memoryRepository) DeleteByFilters(ctx context.Context, filter *Filter) (int64, error) {
+	defer rp.lock(ctx)()
+
+	bulk := *filter
+	bulk.IncludeDeleted = false
+	matched := rp.find(&bulk, false)
+
+	now := time.Now()
+	for _, before := range matched {
+		p := clonePerson(before)
+		p.DeletedAt = &now
+		p.Version++
+		rp.people[p.ID] = p
+		rp.recordHistory(ctx, p.ID, ActionDelete, before, p)
+	}
+	return int64(len(matched)), nil
+}
+
+func (rp *memoryRepository) DeleteByID(ctx context.Context, id int64) error {
+	defer rp.lock(ctx)()
+
+	p, ok := rp.people[id]
+	if !ok || p.DeletedAt != nil {
+		return fmt.Errorf("failed to delete person by id: %w", ErrNotFound)
+	}
+
+	before := clonePerson(p)
+	now := time.Now()
+	p.DeletedAt = &now
+	p.Version++
+	rp.recordHistory(ctx, id, ActionDelete, before, p)
+	return nil
+}
+
+func (rp *memoryRepository) Restore(ctx context.Context, id int64) (*Person, error) {
+	defer rp.lock(ctx)()
+
+	p, ok := rp.people[id]
+	if !ok || p.DeletedAt == nil {
+		return nil, fmt.Errorf("failed to restore person by id: %w", ErrNotFound)
+	}
+	if rp.conflicts(p) {
+		return nil, fmt.Errorf("failed to restore person by id: %w", ErrConflict)
+	}
+
+	before := clonePerson(p)
+	p.DeletedAt, p.UpdatedAt = nil, time.Now()
+	p.Version++
+	rp.recordHistory(ctx, id, ActionRestore, before, p)
+	return clonePerson(p), nil
+}
+
+func (rp *memoryRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	defer rp.lock(ctx)()
+
+	var n int64
+	for id, p := range rp.people {
+		if p.DeletedAt != nil && p.DeletedAt.Before(deletedBefore) {
+			delete(rp.people, id)
+			n++
+		}
+	}
+	rp.history = slices.DeleteFunc(rp.history, func(h *PersonHistory) bool {
+		_, ok := rp.people[h.PersonID]
+		return !ok
+	})
+	return n, nil
+}
+
+func (rp *memoryRepository) Update(ctx context.Context, p *Person) error {
+	defer rp.lock(ctx)()
+
+	current, ok := rp.people[p.ID]
+	if !ok || current.DeletedAt != nil {
+		return fmt.Errorf("failed to update person by id: %w", ErrNotFound)
+	}
+	if current.Version != p.Version {
+		return ErrVersionConflict
+	}
+	if rp.conflicts(p) {
+		return fmt.Errorf("failed to update person by id: %w", ErrConflict)
+	}
+
+	before := clonePerson(current)
+	p.Version++
+	p.UpdatedAt = time.Now()
+	p.CreatedAt, p.DeletedAt = current.CreatedAt, nil
+	rp.people[p.ID] = clonePerson(p)
+	rp.recordHistory(ctx, p.ID, ActionUpdate, before, p)
+	return nil
+}
+
+func (rp *memoryRepository) GetHistory(ctx context.Context, personID int64, limit, offset int) ([]*PersonHistory, error) {
+	defer rp.rlock(ctx)()
+
+	var history []*PersonHistory
+	for i := len(rp.history) - 1; i >= 0; i-- {
+		if h := rp.history[i]; h.PersonID == personID {
+			history = append(history, h)
+		}
+	}
+
+	history = history[min(offset, len(history)):]
+	return history[:min(limit, len(history))], nil
+}
+
+func (rp *memoryRepository) CountHistory(ctx context.Context, personID int64) (int64, error) {
+	defer rp.rlock(ctx)()
+
+	var n int64
+	for _, h := range rp.history {
+		if h.PersonID == personID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// match reports whether p satisfies the predicates of the filter, the
+// in-memory counterpart of addPredicates.
+func (f *Filter) match(p *Person) bool {
+	if !f.IncludeDeleted && p.DeletedAt != nil {
+		return false
+	}
+	if f.Query != "" && !searchMatch(p, f.Query) {
+		return false
+	}
+	if f.Name != "" && p.Name != f.Name {
+		return false
+	}
+	if f.Surname != "" && p.Surname != f.Surname {
+		return false
+	}
+	if f.Age != "" {
+		if age, _ := strconv.Atoi(f.Age); p.Age != age {
+			return false
+		}
+	}
+	if f.AgeMin != "" {
+		if ageMin, _ := strconv.Atoi(f.AgeMin); p.Age < ageMin {
+			return false
+		}
+	}
+	if f.AgeMax != "" {
+		if ageMax, _ := strconv.Atoi(f.AgeMax); p.Age > ageMax {
+			return false
+		}
+	}
+	if len(f.IDs) > 0 && !slices.Contains(f.IDs, p.ID) {
+		return false
+	}
+	if len(f.Gender) > 0 && !slices.Contains(f.Gender, p.Gender) {
+		return false
+	}
+	if len(f.Nationality) > 0 && !slices.Contains(f.Nationality, p.Nationality) {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && p.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !p.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	if !f.UpdatedAfter.IsZero() && p.UpdatedAt.Before(f.UpdatedAfter) {
+		return false
+	}
+	if !f.UpdatedBefore.IsZero() && !p.UpdatedAt.Before(f.UpdatedBefore) {
+		return false
+	}
+	return true
+}
+
+// columnValue returns the value of a sortable column of p, typed as
+// parseColumnValue returns it.
+func columnValue(column string, p *Person) any {
+	switch column {
+	case "id":
+		return p.ID
+	case "name":
+		return p.Name
+	case "surname":
+		return p.Surname
+	case "age":
+		return p.Age
+	case "gender":
+		return p.Gender
+	case "nationality":
+		return p.Nationality
+	case "created_at":
+		return p.CreatedAt
+	case "updated_at":
+		return p.UpdatedAt
+	}
+	panic(fmt.Sprintf("unknown column %q", column))
+}
+
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case int64:
+		return cmp.Compare(a, b.(int64))
+	case int:
+		return cmp.Compare(a, b.(int))
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	panic(fmt.Sprintf("cannot compare %T", a))
+}
+
+// compareSortValues compares p with the sort key values, honoring the
+// direction of every sort term.
+func compareSortValues(sort []SortField, p *Person, values []any) int {
+	for i, field := range sort {
+		c := compareValues(columnValue(field.Column, p), values[i])
+		if field.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareSort(sort []SortField, a, b *Person) int {
+	values := make([]any, len(sort))
+	for i, field := range sort {
+		values[i] = columnValue(field.Column, b)
+	}
+	return compareSortValues(sort, a, values)
+}
+
+func searchMatch(p *Person, term string) bool {
+	lower := strings.ToLower(term)
+	return strings.HasPrefix(strings.ToLower(p.Name), lower) ||
+		strings.HasPrefix(strings.ToLower(p.Surname), lower) ||
+		similarity(p.Name, term) >= similarityThreshold ||
+		similarity(p.Surname, term) >= similarityThreshold
+}
+
+func searchRank(p *Person, term string) float64 {
+	return max(similarity(p.Name, term), similarity(p.Surname, term))
+}
@@ -2,7 +2,10 @@ package repo
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"log/slog"
@@ -10,21 +13,48 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+// ErrVersionConflict is returned by Update when the person was changed
+// since the version being written was read.
+var ErrVersionConflict = errors.New("person was modified concurrently")
+
 type PersonRepository interface {
+	Transactor
 	Create(ctx context.Context, person *Person) (*Person, error)
-	GetByID(ctx context.Context, id int64) (*Person, error)
+	CreateMany(ctx context.Context, people []*Person) error
+	GetByNames(ctx context.Context, people []*Person) ([]*Person, error)
+	GetByID(ctx context.Context, id int64, fields ...string) (*Person, error)
+	GetByIDForUpdate(ctx context.Context, id int64) (*Person, error)
 	GetByFilters(ctx context.Context, filter *Filter) ([]*Person, error)
+	CountByFilters(ctx context.Context, filter *Filter) (int64, error)
+	StreamByFilters(ctx context.Context, filter *Filter, fn func(*Person) error) error
+	UpdateByFilters(ctx context.Context, filter *Filter, changes *PersonChanges) (int64, error)
+	DeleteByFilters(ctx context.Context, filter *Filter) (int64, error)
 	DeleteByID(ctx context.Context, id int64) error
+	Restore(ctx context.Context, id int64) (*Person, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
 	Update(ctx context.Context, p *Person) error
+	GetHistory(ctx context.Context, personID int64, limit, offset int) ([]*PersonHistory, error)
+	CountHistory(ctx context.Context, personID int64) (int64, error)
 }
 
 type personRepository struct {
-	db *sqlx.DB
+	db      *sqlx.DB
+	dialect Dialect
 }
 
 func NewPersonRepository(db *sqlx.DB) PersonRepository {
 	return &personRepository{
-		db: db,
+		db:      db,
+		dialect: Postgres,
+	}
+}
+
+// NewSQLitePersonRepository returns a repository for a SQLite database
+// migrated with the sqlite migration set.
+func NewSQLitePersonRepository(db *sqlx.DB) PersonRepository {
+	return &personRepository{
+		db:      db,
+		dialect: SQLite,
 	}
 }
 
@@ -37,52 +67,164 @@ type Person struct {
 	Nationality string    `db:"nationality" json:"nationality"`
 	CreatedAt   time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+	// Version is incremented by every write, including soft deletes and
+	// restores, and serves as the ETag.
+	Version int `db:"version" json:"version"`
+	// DeletedAt is set while the person is soft deleted.
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+}
+
+// inTx runs fn in a transaction, committing if it returns nil and rolling
+// back otherwise. If ctx already carries a transaction, fn joins it and
+// the outermost InTx decides whether it is committed.
+func (rp *personRepository) inTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return fn(tx)
+	}
+
+	tx, err := rp.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InTx runs fn in a transaction carried by the context passed to it.
+func (rp *personRepository) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return rp.inTx(ctx, func(tx *sqlx.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// conn returns the transaction ctx runs in, or the database outside of one.
+func (rp *personRepository) conn(ctx context.Context) queryer {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return tx
+	}
+	return rp.db
 }
 
 func (rp *personRepository) Create(ctx context.Context, person *Person) (*Person, error) {
+	err := rp.inTx(ctx, func(tx *sqlx.Tx) error {
+		return createTx(ctx, tx, person)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return person, nil
+}
+
+// CreateMany inserts all people in a single transaction; either all of
+// them are created or none is.
+func (rp *personRepository) CreateMany(ctx context.Context, people []*Person) error {
+	return rp.inTx(ctx, func(tx *sqlx.Tx) error {
+		for _, p := range people {
+			if err := createTx(ctx, tx, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetByNames returns the live people that have the name and surname of
+// any of people.
+func (rp *personRepository) GetByNames(ctx context.Context, people []*Person) ([]*Person, error) {
+	if len(people) == 0 {
+		return nil, nil
+	}
+
+	values := make([]string, len(people))
+	args := make([]any, 0, 2*len(people))
+	for i, p := range people {
+		values[i] = fmt.Sprintf("($%d, $%d)", 2*i+1, 2*i+2)
+		args = append(args, p.Name, p.Surname)
+	}
+	query := `
+		SELECT ` + selectColumns(nil) + `
+		FROM people
+		WHERE deleted_at IS NULL AND (name, surname) IN (VALUES ` + strings.Join(values, ", ") + `)
+		ORDER BY id`
+
+	var found []*Person
+	if err := rp.conn(ctx).SelectContext(ctx, &found, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get people by names: %w", err)
+	}
+	return found, nil
+}
+
+func createTx(ctx context.Context, tx *sqlx.Tx, person *Person) error {
 	query := `
 		INSERT INTO people(name, surname, age, gender, nationality)
-		VALUES(:name, :surname, :age, :gender, :nationality)`
+		VALUES(:name, :surname, :age, :gender, :nationality)
+		RETURNING id, created_at, updated_at, version`
 
-	rows, err := rp.db.NamedQueryContext(ctx, query, person)
+	rows, err := sqlx.NamedQueryContext(ctx, tx, query, person)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create person: %w", err)
+		return fmt.Errorf("failed to create person: %w", translateError(err))
 	}
+	defer rows.Close()
 
 	if rows.Next() {
-		err = rows.Scan(person.ID, person.CreatedAt, person.UpdatedAt)
+		err = rows.Scan(&person.ID, &person.CreatedAt, &person.UpdatedAt, &person.Version)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan person: %w", err)
+			return fmt.Errorf("failed to scan person: %w", err)
 		}
 	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("failed to create person: %w", translateError(err))
+	}
 
-	return person, nil
+	return insertHistory(ctx, tx, person.ID, ActionCreate, nil, person)
 }
 
-func (rp *personRepository) GetByID(ctx context.Context, id int64) (*Person, error) {
+func (rp *personRepository) GetByID(ctx context.Context, id int64, fields ...string) (*Person, error) {
 	query := `
-		SELECT id, name, surname, age, gender, nationality, created_at, updated_at
+		SELECT ` + selectColumns(fields, "id", "version") + `
 		FROM people
-		WHERE id=$1;`
+		WHERE id=$1 AND deleted_at IS NULL;`
 
 	var p Person
-	err := rp.db.GetContext(ctx, &p, query, id)
+	err := rp.conn(ctx).GetContext(ctx, &p, query, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch person by id: %w", err)
+		return nil, fmt.Errorf("failed to fetch person by id: %w", translateError(err))
 	}
 
 	return &p, nil
 }
 
+// GetByIDForUpdate returns the person like GetByID and locks its row until
+// the transaction of ctx ends.
+func (rp *personRepository) GetByIDForUpdate(ctx context.Context, id int64) (*Person, error) {
+	var p *Person
+	err := rp.inTx(ctx, func(tx *sqlx.Tx) error {
+		var err error
+		p, err = rp.getForUpdate(ctx, tx, id, false)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch person by id: %w", translateError(err))
+	}
+
+	return p, nil
+}
+
 func (rp *personRepository) GetByFilters(ctx context.Context, filter *Filter) ([]*Person, error) {
 	query := `
-		SELECT id, name, surname, age, gender, nationality, created_at, updated_at 
-		FROM people;`
-	query += filter.String()
-	slog.Info(query)
+		SELECT ` + filter.Columns() + `
+		FROM people`
+	clause, args := filter.Build(rp.dialect)
+	query += clause
+	slog.Debug(query, "args", args)
 
 	var p []*Person
-	err := rp.db.SelectContext(ctx, &p, query)
+	err := rp.conn(ctx).SelectContext(ctx, &p, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch people by filters: %w", err)
 	}
@@ -90,29 +232,218 @@ func (rp *personRepository) GetByFilters(ctx context.Context, filter *Filter) ([
 	return p, nil
 }
 
-func (rp *personRepository) DeleteByID(ctx context.Context, id int64) error {
+// streamChunkSize is the number of rows StreamByFilters reads at a time
+// from SQLite.
+const streamChunkSize = 500
+
+// StreamByFilters calls fn for every person matching filter as rows are
+// read from the database, without loading them all into memory. It stops
+// at the first error returned by fn.
+func (rp *personRepository) StreamByFilters(ctx context.Context, filter *Filter, fn func(*Person) error) error {
+	if rp.dialect == SQLite {
+		return rp.streamInChunks(ctx, filter, fn)
+	}
+
 	query := `
-		DELETE FROM people WHERE id=$1`
+		SELECT ` + filter.Columns() + `
+		FROM people`
+	clause, args := filter.Build(rp.dialect)
+	query += clause
 
-	_, err := rp.db.ExecContext(ctx, query, id)
+	rows, err := rp.conn(ctx).QueryxContext(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to delete person by id: %w", err)
+		return fmt.Errorf("failed to stream people by filters: %w", err)
 	}
+	defer rows.Close()
 
+	for rows.Next() {
+		var p Person
+		if err := rows.StructScan(&p); err != nil {
+			return fmt.Errorf("failed to scan person: %w", err)
+		}
+		if err := fn(&p); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to stream people by filters: %w", err)
+	}
 	return nil
 }
 
+// streamInChunks reads the people matching filter streamChunkSize at a
+// time, so that the single SQLite connection is not held while fn runs,
+// e.g. while an export waits on a slow client. Chunks continue after the
+// last row read, or by offset when the listing is ranked by relevance or
+// not sorted at all.
+func (rp *personRepository) streamInChunks(ctx context.Context, filter *Filter, fn func(*Person) error) error {
+	chunk := *filter
+	remaining := filter.Limit()
+	for {
+		size := streamChunkSize
+		if filter.limit != "" {
+			size = min(size, remaining)
+		}
+		if size == 0 {
+			return nil
+		}
+		chunk.limit = strconv.Itoa(size)
+
+		people, err := rp.GetByFilters(ctx, &chunk)
+		if err != nil {
+			return err
+		}
+		for _, p := range people {
+			if err := fn(p); err != nil {
+				return err
+			}
+		}
+		if len(people) < size {
+			return nil
+		}
+
+		remaining -= len(people)
+		if chunk.rankByQuery || len(chunk.Sort) == 0 {
+			chunk.offset = strconv.Itoa(chunk.Offset() + len(people))
+		} else {
+			chunk.after = sortValues(chunk.Sort, people[len(people)-1])
+			chunk.offset = ""
+		}
+	}
+}
+
+func (rp *personRepository) CountByFilters(ctx context.Context, filter *Filter) (int64, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM people`
+	clause, args := filter.BuildWhere(rp.dialect)
+	query += clause
+
+	var count int64
+	err := rp.conn(ctx).GetContext(ctx, &count, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count people by filters: %w", err)
+	}
+
+	return count, nil
+}
+
+// getForUpdate locks and returns the person with the given id, which must
+// be soft deleted if deleted is set and not deleted otherwise.
+func (rp *personRepository) getForUpdate(ctx context.Context, tx *sqlx.Tx, id int64, deleted bool) (*Person, error) {
+	cond := "deleted_at IS NULL"
+	if deleted {
+		cond = "deleted_at IS NOT NULL"
+	}
+	query := `
+		SELECT ` + selectColumns(nil) + `
+		FROM people
+		WHERE id=$1 AND ` + cond + rp.dialect.forUpdate()
+
+	var p Person
+	if err := tx.GetContext(ctx, &p, query, id); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DeleteByID soft deletes the person; it can be brought back with Restore
+// until it is purged. It returns ErrNotFound if no live person has the id.
+func (rp *personRepository) DeleteByID(ctx context.Context, id int64) error {
+	query := `
+		UPDATE people SET deleted_at = ` + rp.dialect.now() + `, version = version + 1
+		WHERE id=$1
+		RETURNING ` + selectColumns(nil)
+
+	return rp.inTx(ctx, func(tx *sqlx.Tx) error {
+		before, err := rp.getForUpdate(ctx, tx, id, false)
+		if err != nil {
+			return fmt.Errorf("failed to delete person by id: %w", translateError(err))
+		}
+
+		var after Person
+		if err := tx.GetContext(ctx, &after, query, id); err != nil {
+			return fmt.Errorf("failed to delete person by id: %w", translateError(err))
+		}
+
+		return insertHistory(ctx, tx, id, ActionDelete, before, &after)
+	})
+}
+
+func (rp *personRepository) Restore(ctx context.Context, id int64) (*Person, error) {
+	query := `
+		UPDATE people SET deleted_at = NULL, updated_at = ` + rp.dialect.now() + `, version = version + 1
+		WHERE id=$1
+		RETURNING ` + selectColumns(nil)
+
+	var after Person
+	err := rp.inTx(ctx, func(tx *sqlx.Tx) error {
+		before, err := rp.getForUpdate(ctx, tx, id, true)
+		if err != nil {
+			return fmt.Errorf("failed to restore person by id: %w", translateError(err))
+		}
+
+		if err := tx.GetContext(ctx, &after, query, id); err != nil {
+			return fmt.Errorf("failed to restore person by id: %w", translateError(err))
+		}
+
+		return insertHistory(ctx, tx, id, ActionRestore, before, &after)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &after, nil
+}
+
+// Purge permanently removes people soft deleted before deletedBefore and
+// returns how many were removed.
+func (rp *personRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := `
+		DELETE FROM people WHERE deleted_at < $1`
+
+	res, err := rp.conn(ctx).ExecContext(ctx, query, rp.dialect.arg(deletedBefore))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted people: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted people: %w", err)
+	}
+	return n, nil
+}
+
+// Update writes p if it still has the version stored in the database,
+// increments the version and sets its update time. Otherwise it returns
+// ErrVersionConflict.
 func (rp *personRepository) Update(ctx context.Context, p *Person) error {
 	slog.Debug("person on update", "person", *p)
 	query := `
         UPDATE people
-        SET name = :name, surname = :surname, age = :age, nationality = :nationality,
-            gender = :gender, updated_at = :updated_at
-        WHERE id = :id`
+        SET name = $1, surname = $2, age = $3, nationality = $4,
+            gender = $5, updated_at = ` + rp.dialect.now() + `, version = version + 1
+        WHERE id = $6
+        RETURNING updated_at`
 
-	_, err := rp.db.NamedExecContext(ctx, query, p)
-	if err != nil {
-		return fmt.Errorf("failed to update person by id: %w", err)
-	}
-	return nil
+	return rp.inTx(ctx, func(tx *sqlx.Tx) error {
+		before, err := rp.getForUpdate(ctx, tx, p.ID, false)
+		if err != nil {
+			return fmt.Errorf("failed to update person by id: %w", translateError(err))
+		}
+		if before.Version != p.Version {
+			return ErrVersionConflict
+		}
+
+		err = tx.GetContext(ctx, &p.UpdatedAt, query,
+			p.Name, p.Surname, p.Age, p.Nationality, p.Gender, p.ID)
+		if err != nil {
+			return fmt.Errorf("failed to update person by id: %w", translateError(err))
+		}
+		p.Version++
+
+		after := *p
+		after.CreatedAt, after.DeletedAt = before.CreatedAt, before.DeletedAt
+		return insertHistory(ctx, tx, p.ID, ActionUpdate, before, &after)
+	})
 }
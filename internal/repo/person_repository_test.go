@@ -0,0 +1,507 @@
+package repo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/TheTeemka/TaskNameManager/internal/database"
+	"github.com/TheTeemka/TaskNameManager/internal/repo"
+	"github.com/TheTeemka/TaskNameManager/pkg/validator"
+)
+
+// backends returns a constructor of an empty repository for every backend
+// the contract is checked against. Postgres is only included when
+// TEST_PSQL_DBSTRING points at a database the tests may wipe.
+func backends(t *testing.T) map[string]func(t *testing.T) repo.PersonRepository {
+	t.Helper()
+
+	b := map[string]func(t *testing.T) repo.PersonRepository{
+		"memory": func(t *testing.T) repo.PersonRepository {
+			return repo.NewMemoryPersonRepository()
+		},
+		"sqlite": func(t *testing.T) repo.PersonRepository {
+			db := database.OpenSQLite("file::memory:")
+			t.Cleanup(func() { db.Close() })
+
+			migrator, err := database.NewSQLiteMigrator(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := migrator.Up(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			return repo.NewSQLitePersonRepository(db)
+		},
+	}
+
+	if dsn := os.Getenv("TEST_PSQL_DBSTRING"); dsn != "" {
+		b["postgres"] = func(t *testing.T) repo.PersonRepository {
+			db := database.OpenPostgres(dsn)
+			t.Cleanup(func() { db.Close() })
+
+			migrator, err := database.NewPostgresMigrator(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := migrator.Up(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.Exec(`TRUNCATE people, people_history RESTART IDENTITY CASCADE`); err != nil {
+				t.Fatal(err)
+			}
+			return repo.NewPersonRepository(db)
+		}
+	}
+	return b
+}
+
+func TestPersonRepositoryContract(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, r repo.PersonRepository)
+	}{
+		{"create and conflict", testCreate},
+		{"filters", testFilters},
+		{"sort and pagination", testSortAndPagination},
+		{"soft delete, restore and purge", testDeleteRestorePurge},
+		{"versioned update", testVersionedUpdate},
+		{"bulk update and delete", testBulk},
+		{"history", testHistory},
+		{"stream", testStream},
+	}
+
+	for backend, newRepo := range backends(t) {
+		t.Run(backend, func(t *testing.T) {
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					tt.run(t, newRepo(t))
+				})
+			}
+		})
+	}
+}
+
+func testCreate(t *testing.T, r repo.PersonRepository) {
+	ctx := context.Background()
+
+	p := create(t, r, "Ivan", "Petrov", 30, "male", "RU")
+	if p.ID == 0 || p.Version != 1 || p.CreatedAt.IsZero() {
+		t.Fatalf("created person = %+v, want id, version 1 and creation time", p)
+	}
+
+	got, err := r.GetByID(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Ivan" || got.Surname != "Petrov" || got.Age != 30 || got.Gender != "male" || got.Nationality != "RU" {
+		t.Errorf("GetByID = %+v", got)
+	}
+
+	_, err = r.Create(ctx, &repo.Person{Name: "Ivan", Surname: "Petrov"})
+	if !errors.Is(err, repo.ErrConflict) {
+		t.Errorf("Create duplicate: err = %v, want ErrConflict", err)
+	}
+	if _, err := r.GetByID(ctx, p.ID+100); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("GetByID missing: err = %v, want ErrNotFound", err)
+	}
+
+	err = r.CreateMany(ctx, []*repo.Person{
+		{Name: "Anna", Surname: "Ivanova"},
+		{Name: "Anna", Surname: "Ivanova"},
+	})
+	if !errors.Is(err, repo.ErrConflict) {
+		t.Errorf("CreateMany duplicate: err = %v, want ErrConflict", err)
+	}
+	if n := count(t, r, ""); n != 1 {
+		t.Errorf("after failed CreateMany count = %d, want 1", n)
+	}
+
+	found, err := r.GetByNames(ctx, []*repo.Person{{Name: "Ivan", Surname: "Petrov"}, {Name: "Anna", Surname: "Ivanova"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ID != p.ID {
+		t.Errorf("GetByNames = %v, want only %d", personIDs(found), p.ID)
+	}
+}
+
+func testFilters(t *testing.T, r repo.PersonRepository) {
+	ivan := create(t, r, "Ivan", "Petrov", 30, "male", "RU")
+	anna := create(t, r, "Anna", "Ivanova", 25, "female", "KZ")
+	oleg := create(t, r, "Oleg", "Sidorov", 41, "male", "UA")
+	maria := create(t, r, "Maria", "Kuznetsova", 35, "female", "RU")
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"", ids(ivan, anna, oleg, maria)},
+		{"name=Anna", ids(anna)},
+		{"surname=Sidorov", ids(oleg)},
+		{"age=35", ids(maria)},
+		{"age_min=30&age_max=40", ids(ivan, maria)},
+		{"gender=female", ids(anna, maria)},
+		{"gender=", ids(ivan, anna, oleg, maria)},
+		{"nationality=RU,UA", ids(ivan, oleg, maria)},
+		{"nationality=KZ&nationality=UA", ids(anna, oleg)},
+		{"gender=male&nationality=RU", ids(ivan)},
+		{"id=" + strconv.FormatInt(anna.ID, 10) + "," + strconv.FormatInt(oleg.ID, 10), ids(anna, oleg)},
+		{"q=ivan&sort=id", ids(ivan, anna)},
+		{"created_after=2000-01-01", ids(ivan, anna, oleg, maria)},
+		{"created_before=2000-01-01", nil},
+	}
+	for _, tt := range tests {
+		got := list(t, r, tt.query)
+		if !slices.Equal(personIDs(got), tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.query, personIDs(got), tt.want)
+		}
+		if n := count(t, r, tt.query); n != int64(len(tt.want)) {
+			t.Errorf("%q: count = %d, want %d", tt.query, n, len(tt.want))
+		}
+	}
+}
+
+func testSortAndPagination(t *testing.T, r repo.PersonRepository) {
+	a := create(t, r, "Anna", "A", 30, "female", "RU")
+	b := create(t, r, "Boris", "B", 20, "male", "RU")
+	c := create(t, r, "Cyril", "C", 30, "male", "KZ")
+	d := create(t, r, "Dana", "D", 40, "female", "KZ")
+	e := create(t, r, "Egor", "E", 20, "male", "UA")
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"sort=age", ids(b, e, a, c, d)},
+		{"sort=-age", ids(d, a, c, b, e)},
+		{"sort=-age,-id", ids(d, c, a, e, b)},
+		{"sort=nationality,name", ids(c, d, a, b, e)},
+		{"sort=age&limit=2", ids(b, e)},
+		{"sort=age&limit=2&offset=2", ids(a, c)},
+		{"sort=age&limit=0", nil},
+		{"sort=age&offset=10", nil},
+	}
+	for _, tt := range tests {
+		if got := personIDs(list(t, r, tt.query)); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	// Walking the cursor must visit everyone once, in sort order, even
+	// with ties on age.
+	var walked []int64
+	cursor := ""
+	for page := 0; page < 10; page++ {
+		f := filter(t, "sort=-age&limit=2&cursor="+url.QueryEscape(cursor))
+		people, err := r.GetByFilters(context.Background(), f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		walked = append(walked, personIDs(people)...)
+		if cursor = f.NextCursor(people); cursor == "" {
+			break
+		}
+	}
+	if want := ids(d, a, c, b, e); !slices.Equal(walked, want) {
+		t.Errorf("cursor walk = %v, want %v", walked, want)
+	}
+}
+
+func testDeleteRestorePurge(t *testing.T, r repo.PersonRepository) {
+	ctx := context.Background()
+	p := create(t, r, "Ivan", "Petrov", 30, "male", "RU")
+	other := create(t, r, "Anna", "Ivanova", 25, "female", "KZ")
+
+	if err := r.DeleteByID(ctx, p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.GetByID(ctx, p.ID); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("GetByID deleted: err = %v, want ErrNotFound", err)
+	}
+	if err := r.DeleteByID(ctx, p.ID); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("DeleteByID twice: err = %v, want ErrNotFound", err)
+	}
+	if got := personIDs(list(t, r, "")); !slices.Equal(got, ids(other)) {
+		t.Errorf("listing = %v, want %v", got, ids(other))
+	}
+	if got := personIDs(list(t, r, "include_deleted=true")); !slices.Equal(got, ids(p, other)) {
+		t.Errorf("listing with deleted = %v, want %v", got, ids(p, other))
+	}
+
+	restored, err := r.Restore(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.DeletedAt != nil || restored.Version != 3 {
+		t.Errorf("restored = %+v, want live at version 3", restored)
+	}
+	if _, err := r.Restore(ctx, p.ID); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("Restore live: err = %v, want ErrNotFound", err)
+	}
+
+	// A deleted person frees its name, and cannot be restored while it
+	// is taken.
+	if err := r.DeleteByID(ctx, p.ID); err != nil {
+		t.Fatal(err)
+	}
+	create(t, r, "Ivan", "Petrov", 31, "male", "RU")
+	if _, err := r.Restore(ctx, p.ID); !errors.Is(err, repo.ErrConflict) {
+		t.Errorf("Restore taken name: err = %v, want ErrConflict", err)
+	}
+
+	n, err := r.Purge(ctx, time.Now().Add(-time.Hour))
+	if err != nil || n != 0 {
+		t.Errorf("Purge before deletion = %d, %v, want 0", n, err)
+	}
+	n, err = r.Purge(ctx, time.Now().Add(time.Minute))
+	if err != nil || n != 1 {
+		t.Errorf("Purge = %d, %v, want 1", n, err)
+	}
+	if _, err := r.Restore(ctx, p.ID); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("Restore purged: err = %v, want ErrNotFound", err)
+	}
+	if h, err := r.CountHistory(ctx, p.ID); err != nil || h != 0 {
+		t.Errorf("history of purged = %d, %v, want 0", h, err)
+	}
+}
+
+func testVersionedUpdate(t *testing.T, r repo.PersonRepository) {
+	ctx := context.Background()
+	p := create(t, r, "Ivan", "Petrov", 30, "male", "RU")
+	create(t, r, "Anna", "Ivanova", 25, "female", "KZ")
+
+	stale := *p
+	p.Age = 31
+	if err := r.Update(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Version != 2 || p.UpdatedAt.Before(stale.UpdatedAt) {
+		t.Errorf("updated = %+v, want version 2", p)
+	}
+
+	stale.Age = 99
+	if err := r.Update(ctx, &stale); !errors.Is(err, repo.ErrVersionConflict) {
+		t.Errorf("stale Update: err = %v, want ErrVersionConflict", err)
+	}
+
+	p.Name, p.Surname = "Anna", "Ivanova"
+	if err := r.Update(ctx, p); !errors.Is(err, repo.ErrConflict) {
+		t.Errorf("Update to taken name: err = %v, want ErrConflict", err)
+	}
+
+	// A failing unit of work leaves nothing behind.
+	errRollback := errors.New("rollback")
+	err := r.InTx(ctx, func(ctx context.Context) error {
+		locked, err := r.GetByIDForUpdate(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		locked.Age = 50
+		if err := r.Update(ctx, locked); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("InTx: err = %v, want %v", err, errRollback)
+	}
+
+	got, err := r.GetByID(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Age != 31 || got.Name != "Ivan" || got.Version != 2 {
+		t.Errorf("after rollback = %+v, want age 31, name Ivan, version 2", got)
+	}
+}
+
+func testBulk(t *testing.T, r repo.PersonRepository) {
+	ctx := context.Background()
+	ivan := create(t, r, "Ivan", "Petrov", 30, "male", "RU")
+	anna := create(t, r, "Anna", "Ivanova", 25, "female", "KZ")
+	oleg := create(t, r, "Oleg", "Sidorov", 41, "male", "RU")
+
+	n, err := r.UpdateByFilters(ctx, filter(t, "nationality=RU"), &repo.PersonChanges{Nationality: "KZ"})
+	if err != nil || n != 2 {
+		t.Fatalf("UpdateByFilters = %d, %v, want 2", n, err)
+	}
+	if got := personIDs(list(t, r, "nationality=KZ")); !slices.Equal(got, ids(ivan, anna, oleg)) {
+		t.Errorf("after update = %v, want %v", got, ids(ivan, anna, oleg))
+	}
+	if p, _ := r.GetByID(ctx, oleg.ID); p == nil || p.Version != 2 {
+		t.Errorf("bulk updated = %+v, want version 2", p)
+	}
+
+	_, err = r.UpdateByFilters(ctx, filter(t, "gender=male"), &repo.PersonChanges{Name: "Same", Surname: "Name"})
+	if !errors.Is(err, repo.ErrConflict) {
+		t.Errorf("UpdateByFilters to one name: err = %v, want ErrConflict", err)
+	}
+
+	n, err = r.DeleteByFilters(ctx, filter(t, "gender=male"))
+	if err != nil || n != 2 {
+		t.Fatalf("DeleteByFilters = %d, %v, want 2", n, err)
+	}
+	if got := personIDs(list(t, r, "")); !slices.Equal(got, ids(anna)) {
+		t.Errorf("after delete = %v, want %v", got, ids(anna))
+	}
+}
+
+func testHistory(t *testing.T, r repo.PersonRepository) {
+	ctx := repo.WithActor(context.Background(), "alice")
+	p := create(t, r, "Ivan", "Petrov", 30, "male", "RU")
+
+	p.Age = 31
+	if err := r.Update(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.DeleteByID(ctx, p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Restore(ctx, p.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := r.CountHistory(ctx, p.ID)
+	if err != nil || n != 4 {
+		t.Fatalf("CountHistory = %d, %v, want 4", n, err)
+	}
+
+	h, err := r.GetHistory(ctx, p.ID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, e := range h {
+		actions = append(actions, e.Action)
+	}
+	want := []string{repo.ActionRestore, repo.ActionDelete, repo.ActionUpdate, repo.ActionCreate}
+	if !slices.Equal(actions, want) {
+		t.Fatalf("actions = %v, want %v", actions, want)
+	}
+
+	update := h[2]
+	if update.Actor != "alice" || !slices.Equal([]string(update.ChangedFields), []string{"age", "version"}) {
+		t.Errorf("update entry: actor %q, changed %v, want alice and [age version]", update.Actor, update.ChangedFields)
+	}
+	if update.Before.Person == nil || update.Before.Person.Age != 30 || update.After.Person == nil || update.After.Person.Age != 31 {
+		t.Errorf("update entry: before %+v, after %+v, want age 30 then 31", update.Before.Person, update.After.Person)
+	}
+	if h[3].Before.Person != nil {
+		t.Errorf("create entry has a before snapshot: %+v", h[3].Before.Person)
+	}
+
+	page, err := r.GetHistory(ctx, p.ID, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || page[0].Action != repo.ActionDelete {
+		t.Errorf("history page = %d entries starting with %q, want 2 from delete", len(page), page[0].Action)
+	}
+}
+
+func testStream(t *testing.T, r repo.PersonRepository) {
+	ctx := context.Background()
+	people := make([]*repo.Person, 1203)
+	for i := range people {
+		people[i] = &repo.Person{Name: fmt.Sprintf("Person%04d", i), Surname: "Streamed", Age: i % 50}
+	}
+	if err := r.CreateMany(ctx, people); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := []string{
+		"",
+		"sort=-age",
+		"sort=age&limit=700&offset=3",
+		"sort=age&limit=600&cursor=",
+		"q=person 1&fields=name",
+		"limit=0",
+	}
+	for _, query := range queries {
+		var streamed []int64
+		err := r.StreamByFilters(ctx, filter(t, query), func(p *repo.Person) error {
+			streamed = append(streamed, p.ID)
+			if len(streamed) == 1 {
+				// Other requests must not wait for the stream to finish.
+				ctx, cancel := context.WithTimeout(ctx, time.Second)
+				defer cancel()
+				if _, err := r.GetByID(ctx, people[0].ID); err != nil {
+					return fmt.Errorf("GetByID while streaming: %w", err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%q: %v", query, err)
+		}
+		if want := personIDs(list(t, r, query)); !slices.Equal(streamed, want) {
+			t.Errorf("%q: streamed %d people, want the %d listed in the same order", query, len(streamed), len(want))
+		}
+	}
+}
+
+func create(t *testing.T, r repo.PersonRepository, name, surname string, age int, gender, nationality string) *repo.Person {
+	t.Helper()
+
+	p, err := r.Create(context.Background(), &repo.Person{
+		Name: name, Surname: surname, Age: age, Gender: gender, Nationality: nationality,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func filter(t *testing.T, query string) *repo.Filter {
+	t.Helper()
+
+	vals, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := repo.NewFilters()
+	v := validator.New()
+	if f.ParseURL(vals, v); !v.Valid() {
+		t.Fatalf("%q: %v", query, v)
+	}
+	return f
+}
+
+func list(t *testing.T, r repo.PersonRepository, query string) []*repo.Person {
+	t.Helper()
+
+	people, err := r.GetByFilters(context.Background(), filter(t, query))
+	if err != nil {
+		t.Fatalf("%q: %v", query, err)
+	}
+	return people
+}
+
+func count(t *testing.T, r repo.PersonRepository, query string) int64 {
+	t.Helper()
+
+	n, err := r.CountByFilters(context.Background(), filter(t, query))
+	if err != nil {
+		t.Fatalf("%q: %v", query, err)
+	}
+	return n
+}
+
+func ids(people ...*repo.Person) []int64 {
+	return personIDs(people)
+}
+
+func personIDs(people []*repo.Person) []int64 {
+	var ids []int64
+	for _, p := range people {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
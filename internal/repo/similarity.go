@@ -0,0 +1,43 @@
+package repo
+
+import (
+	"strings"
+	"unicode"
+)
+
+// similarityThreshold is the default pg_trgm.similarity_threshold used by
+// the % operator.
+const similarityThreshold = 0.3
+
+// similarity computes the pg_trgm similarity of a and b: the number of
+// trigrams they share divided by the number of distinct trigrams in both.
+func similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// trigrams splits s into lower case words, pads each with two spaces in
+// front and one behind, and returns the set of their three letter runs.
+func trigrams(s string) map[string]bool {
+	set := map[string]bool{}
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/TheTeemka/TaskNameManager/internal/service"
+	"github.com/TheTeemka/TaskNameManager/pkg/utils"
+)
+
+type AdminHandler struct {
+	personService *service.PersonService
+}
+
+func NewAdminHandler(personService *service.PersonService) *AdminHandler {
+	return &AdminHandler{
+		personService: personService,
+	}
+}
+
+type PurgeResponse struct {
+	Purged int64 `json:"purged"`
+}
+
+// @Summary Purge deleted people
+// @Description Permanently remove people soft deleted for longer than the configured retention
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} PurgeResponse
+// @Failure 500 {object} ErrorWrapper "Internal Server Error"
+// @Router /admin/people/purge [post]
+func (h *AdminHandler) PurgeDeleted(w http.ResponseWriter, r *http.Request) {
+	n, err := h.personService.PurgeDeleted(r.Context())
+	if err != nil {
+		handleError(w, "PurgeDeleted", err, "failed to purge deleted people")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	utils.EncodeJson(w, PurgeResponse{Purged: n}, true)
+}
+
+// @Summary Enrichment cache stats
+// @Description Report how many enrichment lookups were answered from memory, from the database or by the providers
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} service.CacheStats
+// @Router /admin/enrichment/cache [get]
+func (h *AdminHandler) EnrichmentCacheStats(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	utils.EncodeJson(w, h.personService.EnrichmentCacheStats(), true)
+}
+
+// @Summary Enrichment provider status
+// @Description Report the circuit breaker state of each enrichment provider
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} service.ProviderStatus
+// @Router /admin/enrichment/providers [get]
+func (h *AdminHandler) EnrichmentProviders(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	utils.EncodeJson(w, h.personService.EnrichmentProviders(), true)
+}
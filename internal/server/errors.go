@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheTeemka/TaskNameManager/internal/repo"
+	"github.com/TheTeemka/TaskNameManager/internal/service"
+)
+
+// handleError responds to err, returned by the service for the operation
+// op, with the status of the domain error it wraps. Any other error is
+// logged and answered with a 500 carrying msg, so that database or driver
+// messages never reach the client.
+func handleError(w http.ResponseWriter, op string, err error, msg string) {
+	var verr *service.ValidationError
+	var uerr *service.UpstreamError
+	var qerr *service.QuotaError
+	var terr *service.ThrottledError
+	switch {
+	case errors.As(err, &verr):
+		ErrorResponseMap(w, verr.Errors, http.StatusUnprocessableEntity)
+	case errors.Is(err, repo.ErrNotFound):
+		ErrorResponse(w, repo.ErrNotFound.Error(), http.StatusNotFound)
+	case errors.Is(err, repo.ErrConflict):
+		ErrorResponse(w, repo.ErrConflict.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrPreconditionFailed):
+		ErrorResponse(w, service.ErrPreconditionFailed.Error(), http.StatusPreconditionFailed)
+	case errors.As(err, &qerr):
+		slog.Warn(op, "error", err)
+		setRetryAfter(w, qerr.ResetAt)
+		ErrorResponseMap(w, map[string]string{
+			"msg":      service.ErrQuotaExhausted.Error(),
+			"provider": qerr.Provider,
+			"reset_at": qerr.ResetAt.UTC().Format(time.RFC3339),
+		}, http.StatusServiceUnavailable)
+	case errors.As(err, &terr):
+		slog.Warn(op, "error", err)
+		setRetryAfter(w, terr.RetryAt)
+		ErrorResponseMap(w, map[string]string{
+			"msg":      service.ErrThrottled.Error(),
+			"provider": terr.Provider,
+			"retry_at": terr.RetryAt.UTC().Format(time.RFC3339),
+		}, http.StatusServiceUnavailable)
+	case errors.As(err, &uerr):
+		slog.Warn(op, "error", err)
+		msg, code := service.ErrUpstreamUnavailable.Error(), http.StatusBadGateway
+		if uerr.Timeout {
+			msg, code = service.ErrUpstreamTimeout.Error(), http.StatusGatewayTimeout
+		}
+		ErrorResponseMap(w, map[string]string{
+			"msg":       msg,
+			"providers": strings.Join(uerr.Providers, ","),
+		}, code)
+	default:
+		slog.Error(op, "error", err)
+		ErrorResponse(w, msg, http.StatusInternalServerError)
+	}
+}
+
+// setRetryAfter tells the client to wait until t, in whole seconds.
+func setRetryAfter(w http.ResponseWriter, t time.Time) {
+	retryAfter := max(int(math.Ceil(time.Until(t).Seconds())), 0)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+}
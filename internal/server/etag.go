@@ -0,0 +1,44 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TheTeemka/TaskNameManager/internal/repo"
+)
+
+var ErrInvalidIfMatch = errors.New("If-Match must be * or a list of ETags")
+
+func setETag(w http.ResponseWriter, p *repo.Person) {
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(p.Version)))
+}
+
+// parseIfMatch returns the versions listed in an If-Match header. It
+// returns nil when the header is empty or "*", i.e. when any version
+// matches. Weak tags never match, as If-Match uses strong comparison.
+func parseIfMatch(header string) ([]int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	versions := []int{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		weak := strings.HasPrefix(tag, "W/")
+		unquoted, err := strconv.Unquote(strings.TrimPrefix(tag, "W/"))
+		if err != nil {
+			return nil, ErrInvalidIfMatch
+		}
+		version, err := strconv.Atoi(unquoted)
+		if err != nil {
+			return nil, ErrInvalidIfMatch
+		}
+		if !weak {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
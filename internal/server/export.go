@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/TheTeemka/TaskNameManager/internal/repo"
+)
+
+// exportFlushEvery is the number of rows written between flushes of an
+// export, so that clients receive data while the export runs.
+const exportFlushEvery = 500
+
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+}
+
+// peopleWriter encodes people one at a time in an export format.
+type peopleWriter interface {
+	Write(p *repo.Person) error
+	Flush() error
+}
+
+func newPeopleWriter(format string, w io.Writer, fields []string) peopleWriter {
+	if fields == nil {
+		fields = repo.PersonFieldNames()
+	}
+	if format == "csv" {
+		return &csvPeopleWriter{w: csv.NewWriter(w), fields: fields}
+	}
+	return &ndjsonPeopleWriter{enc: json.NewEncoder(w), fields: fields}
+}
+
+type csvPeopleWriter struct {
+	w          *csv.Writer
+	fields     []string
+	headerDone bool
+}
+
+func (cw *csvPeopleWriter) writeHeader() error {
+	if cw.headerDone {
+		return nil
+	}
+	cw.headerDone = true
+	return cw.w.Write(cw.fields)
+}
+
+func (cw *csvPeopleWriter) Write(p *repo.Person) error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+
+	values := p.Project(cw.fields)
+	record := make([]string, len(cw.fields))
+	for i, field := range cw.fields {
+		record[i] = csvValue(values[field])
+	}
+	return cw.w.Write(record)
+}
+
+// Flush writes the header even when no person was exported.
+func (cw *csvPeopleWriter) Flush() error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func csvValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
+
+type ndjsonPeopleWriter struct {
+	enc    *json.Encoder
+	fields []string
+}
+
+func (nw *ndjsonPeopleWriter) Write(p *repo.Person) error {
+	return nw.enc.Encode(p.Project(nw.fields))
+}
+
+func (nw *ndjsonPeopleWriter) Flush() error {
+	return nil
+}
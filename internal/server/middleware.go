@@ -0,0 +1,24 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/TheTeemka/TaskNameManager/internal/repo"
+)
+
+// ActorHeader names the request header that identifies who makes a
+// change; its value is recorded in the people history.
+const ActorHeader = "X-Actor"
+
+const maxActorLength = 100
+
+func Actor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := r.Header.Get(ActorHeader)
+		if len(actor) > maxActorLength {
+			actor = actor[:maxActorLength]
+		}
+		ctx := repo.WithActor(r.Context(), actor)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
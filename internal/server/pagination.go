@@ -0,0 +1,121 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/TheTeemka/TaskNameManager/internal/repo"
+	"github.com/TheTeemka/TaskNameManager/pkg/validator"
+)
+
+type PeoplePage struct {
+	// Items holds either *repo.Person values or, when a sparse fieldset
+	// was requested, maps with only those fields.
+	Items      []any  `json:"items"`
+	Total      int64  `json:"total"`
+	Limit      int    `json:"limit,omitempty"`
+	Offset     *int   `json:"offset,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func newPeoplePage(filter *repo.Filter, people []*repo.Person, total int64) *PeoplePage {
+	page := &PeoplePage{
+		Items: projectPeople(people, filter.Fields),
+		Total: total,
+		Limit: filter.Limit(),
+	}
+	if filter.UsesCursor() {
+		page.NextCursor = filter.NextCursor(people)
+	} else {
+		offset := filter.Offset()
+		page.Offset = &offset
+	}
+	return page
+}
+
+type HistoryPage struct {
+	Items  []*repo.PersonHistory `json:"items"`
+	Total  int64                 `json:"total"`
+	Limit  int                   `json:"limit"`
+	Offset int                   `json:"offset"`
+}
+
+// DefaultHistoryLimit is the page size of the history listing when the
+// request does not set a limit.
+const DefaultHistoryLimit = 50
+
+// parsePaging reads limit and offset query parameters for listings that
+// are always paginated.
+func parsePaging(vals url.Values, defaultLimit int, v *validator.Validator) (limit, offset int) {
+	limit = defaultLimit
+	if s := vals.Get("limit"); s != "" {
+		v.CheckWithRules("limit", s, validator.IsInt(32))
+		limit, _ = strconv.Atoi(s)
+		v.Check(limit > 0, "limit", "must be positive")
+	}
+
+	if s := vals.Get("offset"); s != "" {
+		v.CheckWithRules("offset", s, validator.IsInt(32))
+		offset, _ = strconv.Atoi(s)
+		v.Check(offset >= 0, "offset", "must not be negative")
+	}
+	return limit, offset
+}
+
+// projectPeople narrows people to fields for sparse fieldsets. A nil fields
+// list keeps the people as they are.
+func projectPeople(people []*repo.Person, fields []string) []any {
+	items := make([]any, len(people))
+	for i, p := range people {
+		items[i] = projectPerson(p, fields)
+	}
+	return items
+}
+
+func projectPerson(p *repo.Person, fields []string) any {
+	if fields == nil {
+		return p
+	}
+	return p.Project(fields)
+}
+
+// setLinkHeader advertises the neighbouring pages of page as RFC 8288
+// Link header values built from the request URL.
+func setLinkHeader(w http.ResponseWriter, r *http.Request, page *PeoplePage) {
+	var links []string
+	link := func(rel string, set map[string]string) {
+		vals := r.URL.Query()
+		for k, v := range set {
+			vals.Set(k, v)
+		}
+		u := url.URL{Path: r.URL.Path, RawQuery: vals.Encode()}
+		links = append(links, fmt.Sprintf("<%s>; rel=%q", u.String(), rel))
+	}
+
+	switch {
+	case page.Offset == nil:
+		if page.NextCursor != "" {
+			link("next", map[string]string{"cursor": page.NextCursor})
+		}
+	case page.Limit > 0:
+		offset, limit := int64(*page.Offset), int64(page.Limit)
+		lastOffset := max((page.Total-1)/limit*limit, 0)
+
+		link("first", map[string]string{"offset": "0"})
+		if offset > 0 {
+			prev := max(offset-limit, 0)
+			link("prev", map[string]string{"offset": strconv.FormatInt(prev, 10)})
+		}
+		if offset+limit < page.Total {
+			link("next", map[string]string{"offset": strconv.FormatInt(offset+limit, 10)})
+		}
+		link("last", map[string]string{"offset": strconv.FormatInt(lastOffset, 10)})
+	}
+
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
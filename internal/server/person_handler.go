@@ -1,12 +1,16 @@
 package server
 
 import (
-	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/TheTeemka/TaskNameManager/internal/repo"
 	"github.com/TheTeemka/TaskNameManager/internal/service"
@@ -15,8 +19,6 @@ import (
 	"github.com/go-chi/chi"
 )
 
-var ErrNotFound = errors.New("resourse not found")
-
 type PersonHandler struct {
 	personService *service.PersonService
 }
@@ -33,46 +35,197 @@ func NewPersonHandler(PersonService *service.PersonService) *PersonHandler {
 // @Accept json
 // @Produce json
 // @Param person body service.CreatePersonReq true "Person to create"
+// @Param X-Actor header string false "Who makes the change, recorded in the history"
 // @Success 201 {object} repo.Person
 // @Failure 400 {object} ErrorWrapper "Bad Request"
+// @Failure 409 {object} ErrorWrapper "Conflict"
+// @Failure 422 {object} ErrorWrapper "Unprocessable Entity"
 // @Failure 500 {object} ErrorWrapper "Internal Server Error"
+// @Failure 502 {object} ErrorWrapper "Bad Gateway"
+// @Failure 503 {object} ErrorWrapper "Service Unavailable"
+// @Failure 504 {object} ErrorWrapper "Gateway Timeout"
 // @Router /people [post]
 func (h *PersonHandler) CreatePerson(w http.ResponseWriter, r *http.Request) {
 	req, err := utils.DecodeJson[service.CreatePersonReq](r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req == nil {
+		ErrorResponse(w, "request body must be a JSON object", http.StatusBadRequest)
+		return
+	}
+
+	p, err := h.personService.CreatePerson(r.Context(), req)
+	if err != nil {
+		handleError(w, "CreatePerson", err, "failed to create person")
 		return
 	}
 
+	w.WriteHeader(http.StatusCreated)
+	utils.EncodeJson(w, p, true)
+}
+
+type BatchCreateResponse struct {
+	Results []*service.BatchItemResult `json:"results"`
+}
+
+// @Summary Create people in batch
+// @Description Validate, enrich and create many people at once.
+// @Description In atomic mode (default) either every person is created in one transaction or none is;
+// @Description in best_effort mode each person is created on its own. Results are reported per item.
+// @Description In atomic mode a name and surname repeated in the batch or already taken fails its item before any lookup.
+// @Tags People
+// @Accept json
+// @Produce json
+// @Param people body []service.CreatePersonReq true "People to create"
+// @Param mode query string false "atomic or best_effort" Enums(atomic, best_effort) default(atomic)
+// @Param X-Actor header string false "Who makes the change, recorded in the history"
+// @Success 201 {object} BatchCreateResponse "All people created"
+// @Success 207 {object} BatchCreateResponse "Some people were not created"
+// @Failure 400 {object} ErrorWrapper "Bad Request"
+// @Failure 409 {object} ErrorWrapper "Conflict"
+// @Failure 422 {object} ErrorWrapper "Unprocessable Entity"
+// @Failure 500 {object} ErrorWrapper "Internal Server Error"
+// @Router /people/batch [post]
+func (h *PersonHandler) BatchCreate(w http.ResponseWriter, r *http.Request) {
 	v := validator.New()
-	req.Validate(v)
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "atomic"
+	}
+	v.Check(mode == "atomic" || mode == "best_effort", "mode", "must be atomic or best_effort")
 	if !v.Valid() {
 		ErrorResponseMap(w, v.Errors, http.StatusBadRequest)
 		return
 	}
+	atomic := mode == "atomic"
 
-	p, err := h.personService.CreatePerson(req)
+	reqs, err := utils.DecodeJson[[]*service.CreatePersonReq](r.Body)
 	if err != nil {
-		slog.Error("CreatePerson", "error", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if reqs == nil || len(*reqs) == 0 || len(*reqs) > service.MaxBatchSize {
+		ErrorResponse(w, fmt.Sprintf("batch must contain between 1 and %d people", service.MaxBatchSize), http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	utils.EncodeJson(w, p, true)
+	results := make([]*service.BatchItemResult, len(*reqs))
+	for i, req := range *reqs {
+		iv := validator.New()
+		if req == nil {
+			iv.AddError("msg", "must not be null")
+		} else {
+			req.Validate(iv)
+		}
+		if !iv.Valid() {
+			v.Merge(fmt.Sprintf("[%d].", i), iv)
+			results[i] = &service.BatchItemResult{Index: i, Status: service.BatchStatusFailed, Errors: iv.Errors}
+		}
+	}
+	if atomic && !v.Valid() {
+		ErrorResponseMap(w, v.Errors, http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = h.personService.BatchCreate(r.Context(), *reqs, results, atomic)
+	if err != nil {
+		handleError(w, "BatchCreate", err, "failed to create people")
+		return
+	}
+
+	status := http.StatusCreated
+	for _, res := range results {
+		if res.Status != service.BatchStatusCreated {
+			status = http.StatusMultiStatus
+			break
+		}
+	}
+
+	w.WriteHeader(status)
+	utils.EncodeJson(w, BatchCreateResponse{Results: results}, true)
+}
+
+// maxImportSize caps the size of an uploaded import file.
+const maxImportSize = 10 << 20
+
+// @Summary Import people from CSV
+// @Description Register the people listed in a CSV file with the columns name, surname and optionally age, gender and nationality.
+// @Description The file is sent either as the multipart form field "file" or as a text/csv body.
+// @Description Existing or repeated people are skipped and invalid rows are rejected, each with a reason.
+// @Tags People
+// @Accept multipart/form-data
+// @Accept text/csv
+// @Produce json
+// @Param file formData file false "CSV file"
+// @Param dry_run query bool false "Only validate the file without creating anyone"
+// @Param X-Actor header string false "Who makes the change, recorded in the history"
+// @Success 200 {object} service.ImportReport
+// @Failure 400 {object} ErrorWrapper "Bad Request"
+// @Failure 500 {object} ErrorWrapper "Internal Server Error"
+// @Router /people/import [post]
+func (h *PersonHandler) Import(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	dryRun := queryBool(r.URL.Query(), "dry_run", false, v)
+	if !v.Valid() {
+		ErrorResponseMap(w, v.Errors, http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			ErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	report, err := h.personService.Import(r.Context(), body, dryRun)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidImport) {
+			ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		} else {
+			handleError(w, "Import", err, "failed to import people")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	utils.EncodeJson(w, report, true)
 }
 
 // @Summary Get people by filters
-// @Description Retrieve a list of people based on query parameters
+// @Description Retrieve a page of people based on query parameters.
+// @Description Neighbouring pages are advertised in the Link header.
 // @Tags People
 // @Accept json
 // @Produce json
+// @Param q query string false "Fuzzy search over name and surname, ranked by similarity unless sort is given"
 // @Param name query string false "Filter by name"
 // @Param surname query string false "Filter by surname"
 // @Param age query int false "Filter by age"
-// @Param gender query string false "Filter by gender"
-// @Param nationality query string false "Filter by nationality"
-// @Success 200 {array} repo.Person
+// @Param id query []int false "Filter by any of the ids" collectionFormat(csv)
+// @Param gender query []string false "Filter by any of the genders" collectionFormat(csv)
+// @Param nationality query []string false "Filter by any of the nationalities" collectionFormat(csv)
+// @Param age_min query int false "Minimum age, inclusive"
+// @Param age_max query int false "Maximum age, inclusive"
+// @Param created_after query string false "Created at or after (RFC3339 or YYYY-MM-DD)"
+// @Param created_before query string false "Created before (RFC3339 or YYYY-MM-DD)"
+// @Param updated_after query string false "Updated at or after (RFC3339 or YYYY-MM-DD)"
+// @Param updated_before query string false "Updated before (RFC3339 or YYYY-MM-DD)"
+// @Param sort query string false "Comma separated sort columns, prefix with - for descending (e.g. surname,-age)"
+// @Param limit query int false "Maximum number of people to return"
+// @Param offset query int false "Number of people to skip"
+// @Param cursor query string false "Opaque cursor from next_cursor; pass it empty to start keyset pagination"
+// @Param fields query string false "Comma separated fields to return (e.g. id,name,nationality)"
+// @Param include_deleted query bool false "Also list soft deleted people" default(false)
+// @Param envelope query bool false "Set to false to get a bare array instead of a page" default(true)
+// @Success 200 {object} PeoplePage{items=[]repo.Person}
 // @Failure 400 {object} ErrorWrapper "Bad Request"
 // @Failure 500 {object} ErrorWrapper "Internal Server Error"
 // @Router /people [get]
@@ -81,21 +234,192 @@ func (h *PersonHandler) GetByFilters(w http.ResponseWriter, r *http.Request) {
 	filter := repo.NewFilters()
 	v := validator.New()
 	filter.ParseURL(vals, v)
+	envelope := queryBool(vals, "envelope", true, v)
 	if !v.Valid() {
 		http.Error(w, v.String(), http.StatusBadRequest)
 		return
 	}
 
-	people, err := h.personService.GetByFilters(filter)
+	people, err := h.personService.GetByFilters(r.Context(), filter)
+	if err != nil {
+		handleError(w, "GetByFilters", err, "Failed to get people")
+		return
+	}
+
+	total, err := h.personService.CountByFilters(r.Context(), filter)
+	if err != nil {
+		handleError(w, "CountByFilters", err, "Failed to count people")
+		return
+	}
+
+	page := newPeoplePage(filter, people, total)
+	setLinkHeader(w, r, page)
+
+	w.WriteHeader(http.StatusOK)
+	if !envelope {
+		utils.EncodeJson(w, page.Items, true)
+		return
+	}
+	utils.EncodeJson(w, page, true)
+}
+
+type BulkResponse struct {
+	Affected int64 `json:"affected"`
+	DryRun   bool  `json:"dry_run"`
+}
+
+// parseBulkFilter parses the filter of a bulk operation. A filter that
+// matches everyone must be confirmed explicitly.
+func parseBulkFilter(vals url.Values, v *validator.Validator) (filter *repo.Filter, dryRun bool) {
+	filter = repo.NewFilters()
+	filter.ParseURL(vals, v)
+	filter.IncludeDeleted = false
+	// Bulk operations always apply to the whole matching set; a page
+	// or projection would suggest otherwise.
+	for _, key := range []string{"limit", "offset", "cursor", "sort", "fields"} {
+		v.Check(!vals.Has(key), key, "is not supported by bulk operations")
+	}
+
+	confirm := queryBool(vals, "confirm", false, v)
+	dryRun = queryBool(vals, "dry_run", false, v)
+	if v.Valid() && !filter.HasPredicates() && !confirm {
+		v.AddError("confirm", "must be true to apply the operation to every person")
+	}
+	return filter, dryRun
+}
+
+// @Summary Update people by filters
+// @Description Apply the same changes to every person matching the GET /people query filters in one transaction.
+// @Description Without any filter the request must set confirm=true. limit, offset, cursor, sort and fields are rejected.
+// @Tags People
+// @Accept json
+// @Produce json
+// @Param person body service.UpdatePersonReq true "Changes to apply"
+// @Param confirm query bool false "Required to update everyone when no filter is given"
+// @Param dry_run query bool false "Only report how many people would be updated"
+// @Param X-Actor header string false "Who makes the change, recorded in the history"
+// @Success 200 {object} BulkResponse
+// @Failure 400 {object} ErrorWrapper "Bad Request"
+// @Failure 409 {object} ErrorWrapper "Conflict"
+// @Failure 422 {object} ErrorWrapper "Unprocessable Entity"
+// @Failure 500 {object} ErrorWrapper "Internal Server Error"
+// @Router /people [patch]
+func (h *PersonHandler) UpdateByFilters(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	filter, dryRun := parseBulkFilter(r.URL.Query(), v)
+	if !v.Valid() {
+		ErrorResponseMap(w, v.Errors, http.StatusBadRequest)
+		return
+	}
+
+	req, err := utils.DecodeJson[service.UpdatePersonReq](r.Body)
+	if err != nil {
+		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req == nil {
+		ErrorResponse(w, "request body must be a JSON object", http.StatusBadRequest)
+		return
+	}
+
+	n, err := h.personService.UpdateByFilters(r.Context(), filter, req, dryRun)
+	if err != nil {
+		handleError(w, "UpdateByFilters", err, "failed to update people")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	utils.EncodeJson(w, BulkResponse{Affected: n, DryRun: dryRun}, true)
+}
+
+// @Summary Delete people by filters
+// @Description Soft delete every person matching the GET /people query filters in one transaction.
+// @Description Without any filter the request must set confirm=true. limit, offset, cursor, sort and fields are rejected.
+// @Tags People
+// @Produce json
+// @Param confirm query bool false "Required to delete everyone when no filter is given"
+// @Param dry_run query bool false "Only report how many people would be deleted"
+// @Param X-Actor header string false "Who makes the change, recorded in the history"
+// @Success 200 {object} BulkResponse
+// @Failure 400 {object} ErrorWrapper "Bad Request"
+// @Failure 500 {object} ErrorWrapper "Internal Server Error"
+// @Router /people [delete]
+func (h *PersonHandler) DeleteByFilters(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	filter, dryRun := parseBulkFilter(r.URL.Query(), v)
+	if !v.Valid() {
+		ErrorResponseMap(w, v.Errors, http.StatusBadRequest)
+		return
+	}
+
+	n, err := h.personService.DeleteByFilters(r.Context(), filter, dryRun)
 	if err != nil {
-		slog.Error("GetByFilters", "error", err)
-		ErrorResponse(w, "Failed to get people", http.StatusInternalServerError)
+		handleError(w, "DeleteByFilters", err, "failed to delete people")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	utils.EncodeJson(w, BulkResponse{Affected: n, DryRun: dryRun}, true)
+}
+
+// @Summary Export people
+// @Description Stream every person matching the GET /people query filters as CSV or NDJSON.
+// @Tags People
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string false "Export format" Enums(csv, ndjson) default(csv)
+// @Param fields query string false "Comma separated fields to export (e.g. id,name,nationality)"
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorWrapper "Bad Request"
+// @Failure 500 {object} ErrorWrapper "Internal Server Error"
+// @Router /people/export [get]
+func (h *PersonHandler) Export(w http.ResponseWriter, r *http.Request) {
+	vals := r.URL.Query()
+	filter := repo.NewFilters()
+	v := validator.New()
+	filter.ParseURL(vals, v)
+	format := vals.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	contentType, ok := exportContentTypes[format]
+	v.Check(ok, "format", "must be csv or ndjson")
+	if !v.Valid() {
+		ErrorResponseMap(w, v.Errors, http.StatusBadRequest)
 		return
 	}
 
+	filename := fmt.Sprintf("people-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
 	w.WriteHeader(http.StatusOK)
-	utils.EncodeJson(w, people, true)
 
+	rc := http.NewResponseController(w)
+	pw := newPeopleWriter(format, w, filter.Fields)
+	rows := 0
+	err := h.personService.Export(r.Context(), filter, func(p *repo.Person) error {
+		if err := pw.Write(p); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%exportFlushEvery == 0 {
+			if err := pw.Flush(); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = pw.Flush()
+	}
+	if err != nil {
+		// The status has already been sent; abort the connection so that
+		// the client sees a broken transfer rather than a short file.
+		slog.Error("Export", "error", err, "rows", rows)
+		panic(http.ErrAbortHandler)
+	}
 }
 
 // @Summary Get a person by ID
@@ -104,7 +428,9 @@ func (h *PersonHandler) GetByFilters(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Person ID"
+// @Param fields query string false "Comma separated fields to return (e.g. id,name,nationality)"
 // @Success 200 {object} repo.Person
+// @Header 200 {string} ETag "Version of the person"
 // @Failure 400 {object} ErrorWrapper "Bad Request"
 // @Failure 404 {object} ErrorWrapper "Not Found"
 // @Failure 500 {object} ErrorWrapper "Internal Server Error"
@@ -117,28 +443,32 @@ func (h *PersonHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	person, err := h.personService.GetByID(id)
+	v := validator.New()
+	fields := repo.ParseFields(r.URL.Query().Get("fields"), v)
+	if !v.Valid() {
+		ErrorResponseMap(w, v.Errors, http.StatusBadRequest)
+		return
+	}
+
+	person, err := h.personService.GetByID(r.Context(), id, fields...)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ErrorResponse(w, ErrNotFound.Error(), http.StatusNotFound)
-		} else {
-			slog.Error("GetByID", "error", err)
-			ErrorResponse(w, "failed to get person", http.StatusInternalServerError)
-		}
+		handleError(w, "GetByID", err, "failed to get person")
 		return
 	}
 
+	setETag(w, person)
 	w.WriteHeader(http.StatusOK)
-	utils.EncodeJson(w, person, true)
+	utils.EncodeJson(w, projectPerson(person, fields), true)
 
 }
 
 // @Summary Delete a person by ID
-// @Description Delete a person from the database by their ID
+// @Description Soft delete a person by their ID. It can be restored until it is purged.
 // @Tags People
 // @Accept json
 // @Produce json
 // @Param id path int true "Person ID"
+// @Param X-Actor header string false "Who makes the change, recorded in the history"
 // @Success 204 "No Content"
 // @Failure 400 {object} ErrorWrapper "Bad Request"
 // @Failure 404 {object} ErrorWrapper "Not Found"
@@ -152,16 +482,90 @@ func (h *PersonHandler) DeleteByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.personService.DeleteByID(id)
+	err = h.personService.DeleteByID(r.Context(), id)
 	if err != nil {
-		slog.Error("DeleteByID", "error", err)
-		ErrorResponse(w, "failed to delete person", http.StatusInternalServerError)
+		handleError(w, "DeleteByID", err, "failed to delete person")
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// @Summary Restore a deleted person
+// @Description Restore a soft deleted person by their ID
+// @Tags People
+// @Produce json
+// @Param id path int true "Person ID"
+// @Param X-Actor header string false "Who makes the change, recorded in the history"
+// @Success 200 {object} repo.Person
+// @Failure 400 {object} ErrorWrapper "Bad Request"
+// @Failure 404 {object} ErrorWrapper "Not Found"
+// @Failure 409 {object} ErrorWrapper "Conflict"
+// @Failure 500 {object} ErrorWrapper "Internal Server Error"
+// @Router /people/{id}/restore [post]
+func (h *PersonHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p, err := h.personService.Restore(r.Context(), id)
+	if err != nil {
+		handleError(w, "Restore", err, "failed to restore person")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	utils.EncodeJson(w, p, true)
+}
+
+// @Summary Get the change history of a person
+// @Description Retrieve the recorded changes of a person, newest first
+// @Tags People
+// @Produce json
+// @Param id path int true "Person ID"
+// @Param limit query int false "Maximum number of entries to return" default(50)
+// @Param offset query int false "Number of entries to skip"
+// @Success 200 {object} HistoryPage
+// @Failure 400 {object} ErrorWrapper "Bad Request"
+// @Failure 404 {object} ErrorWrapper "Not Found"
+// @Failure 500 {object} ErrorWrapper "Internal Server Error"
+// @Router /people/{id}/history [get]
+func (h *PersonHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	v := validator.New()
+	limit, offset := parsePaging(r.URL.Query(), DefaultHistoryLimit, v)
+	if !v.Valid() {
+		ErrorResponseMap(w, v.Errors, http.StatusBadRequest)
+		return
+	}
+
+	history, total, err := h.personService.GetHistory(r.Context(), id, limit, offset)
+	if err != nil {
+		handleError(w, "GetHistory", err, "failed to get person history")
+		return
+	}
+	if history == nil {
+		history = []*repo.PersonHistory{}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	utils.EncodeJson(w, HistoryPage{
+		Items:  history,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	}, true)
+}
+
 // @Summary Update a person by ID
 // @Description Update the details of a person by their ID
 // @Tags People
@@ -169,9 +573,15 @@ func (h *PersonHandler) DeleteByID(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param id path int true "Person ID"
 // @Param person body service.UpdatePersonReq true "Updated person details"
+// @Param X-Actor header string false "Who makes the change, recorded in the history"
+// @Param If-Match header string false "ETag the update is based on"
 // @Success 200 {object} repo.Person
+// @Header 200 {string} ETag "Version of the person"
 // @Failure 400 {object} ErrorWrapper "Bad Request"
 // @Failure 404 {object} ErrorWrapper "Not Found"
+// @Failure 412 {object} ErrorWrapper "Precondition Failed"
+// @Failure 409 {object} ErrorWrapper "Conflict"
+// @Failure 422 {object} ErrorWrapper "Unprocessable Entity"
 // @Failure 500 {object} ErrorWrapper "Internal Server Error"
 // @Router /people/{id} [patch]
 func (h *PersonHandler) UpdateByID(w http.ResponseWriter, r *http.Request) {
@@ -184,28 +594,27 @@ func (h *PersonHandler) UpdateByID(w http.ResponseWriter, r *http.Request) {
 
 	req, err := utils.DecodeJson[service.UpdatePersonReq](r.Body)
 	if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req == nil {
+		ErrorResponse(w, "request body must be a JSON object", http.StatusBadRequest)
 		return
 	}
 
-	v := validator.New()
-	req.Validate(v)
-	if !v.Valid() {
-		ErrorResponseMap(w, v.Errors, http.StatusBadRequest)
+	ifMatch, err := parseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		ErrorResponse(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	p, err := h.personService.UpdateByID(id, req)
+	p, err := h.personService.UpdateByID(r.Context(), id, req, ifMatch)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ErrorResponse(w, ErrNotFound.Error(), http.StatusNotFound)
-		} else {
-			slog.Error("GetByID", "error", err)
-			ErrorResponse(w, "failed to update person", http.StatusInternalServerError)
-		}
+		handleError(w, "UpdateByID", err, "failed to update person")
 		return
 	}
 
+	setETag(w, p)
 	w.WriteHeader(http.StatusOK)
 	utils.EncodeJson(w, p, true)
 }
@@ -231,3 +640,15 @@ func ErrorResponseMap(w http.ResponseWriter, mp map[string]string, code int) {
 		Errors: mp,
 	})
 }
+
+// queryBool reads an optional boolean query parameter.
+func queryBool(vals url.Values, key string, def bool, v *validator.Validator) bool {
+	s := vals.Get(key)
+	if s == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(s)
+	v.Check(err == nil, key, "must be a boolean")
+	return b
+}
@@ -11,12 +11,26 @@ import (
 func (s *Server) Router() http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
+	r.Use(Actor)
 	r.Route("/people", func(r chi.Router) {
 		r.Post("/", s.PersonHandler.CreatePerson)
+		r.Post("/batch", s.PersonHandler.BatchCreate)
+		r.Post("/import", s.PersonHandler.Import)
 		r.Get("/", s.PersonHandler.GetByFilters)
+		r.Patch("/", s.PersonHandler.UpdateByFilters)
+		r.Delete("/", s.PersonHandler.DeleteByFilters)
+		r.Get("/export", s.PersonHandler.Export)
 		r.Get("/{id}", s.PersonHandler.GetByID)
 		r.Delete("/{id}", s.PersonHandler.DeleteByID)
 		r.Patch("/{id}", s.PersonHandler.UpdateByID)
+		r.Post("/{id}/restore", s.PersonHandler.Restore)
+		r.Get("/{id}/history", s.PersonHandler.GetHistory)
+	})
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Post("/people/purge", s.AdminHandler.PurgeDeleted)
+		r.Get("/enrichment/cache", s.AdminHandler.EnrichmentCacheStats)
+		r.Get("/enrichment/providers", s.AdminHandler.EnrichmentProviders)
 	})
 
 	r.Get("/swagger/*", httpSwagger.WrapHandler)
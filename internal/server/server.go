@@ -15,12 +15,14 @@ import (
 type Server struct {
 	Port          string
 	PersonHandler *PersonHandler
+	AdminHandler  *AdminHandler
 }
 
 func NewServer(Port string, personService *service.PersonService) *Server {
 	return &Server{
 		Port:          Port,
 		PersonHandler: NewPersonHandler(personService),
+		AdminHandler:  NewAdminHandler(personService),
 	}
 }
 
@@ -1,96 +1,319 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheTeemka/TaskNameManager/pkg/breaker"
 )
 
-func fetchAge(name string) (int, error) {
-	url := fmt.Sprintf("https://api.agify.io/?name=%s", name)
-	resp, err := http.Get(url)
-	if err != nil {
-		return 0, fmt.Errorf("failed to fetch age: %w", err)
-	}
-	defer resp.Body.Close()
+// Enricher looks up the age, gender and nationality most likely for a
+// first name.
+type Enricher interface {
+	Age(ctx context.Context, name string) (int, error)
+	Gender(ctx context.Context, name string) (string, error)
+	Nationality(ctx context.Context, name string) (string, error)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		slog.Error("failed to fetch age", "status_code", resp.StatusCode)
-		return 0, fmt.Errorf("failed to fetch age: %s", resp.Status)
+// ProviderConfig describes where and how an enrichment provider is called.
+type ProviderConfig struct {
+	// BaseURL is queried with ?name=..., e.g. https://api.agify.io.
+	BaseURL string
+	// APIKey is sent as the apikey parameter when set.
+	APIKey string
+	// Timeout bounds a single request; zero means no timeout.
+	Timeout time.Duration
+	// Retries is how often a request failing with a network error, a 429
+	// or a 5xx is repeated. The n-th retry waits RetryBackoff*2^n with
+	// jitter, or as long as the Retry-After header asks.
+	Retries      int
+	RetryBackoff time.Duration
+	// BreakerThreshold consecutive failed lookups stop calls to the
+	// provider for BreakerCooldown; zero never stops them.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// ThrottleBelow is the fraction of the rate limit below which calls
+	// are spread out until the limit resets; zero only stops them once it
+	// is used up.
+	ThrottleBelow float64
+}
+
+// maxRetryWait caps the backoff between two attempts.
+const maxRetryWait = 10 * time.Second
+
+// provider is one of the name lookup APIs of agify.io, genderize.io and
+// nationalize.io, or a service compatible with them.
+type provider struct {
+	name    string
+	client  *http.Client
+	cfg     ProviderConfig
+	breaker *breaker.Breaker
+	quota   *quota
+}
+
+func newProvider(name string, client *http.Client, cfg ProviderConfig) *provider {
+	return &provider{
+		name:    name,
+		client:  client,
+		cfg:     cfg,
+		breaker: breaker.New(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		quota:   &quota{throttleBelow: cfg.ThrottleBelow},
 	}
+}
 
-	slog.Info("api.genderize.io",
-		"X-Rate-Limit-Remaining", resp.Header.Get("X-Rate-Limit-Remaining"),
-	)
+// retryableError is a failed attempt that is worth repeating, after
+// retryAfter if the provider asked for it.
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
 
-	var result struct {
-		Age int `json:"age"`
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// get queries the provider for name and decodes the JSON response into v,
+// retrying transient failures. Calls fail fast while the breaker is open
+// and wait or fail while the provider's quota is low.
+func (p *provider) get(ctx context.Context, name string, v any) error {
+	if err := p.breaker.Allow(); err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", p.name, err)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0, err
+
+	err := p.getWithRetry(ctx, name, v)
+	switch {
+	case err == nil:
+		p.breaker.Success()
+	case errors.Is(ctx.Err(), context.Canceled), errors.Is(err, ErrQuotaExhausted), errors.Is(err, ErrThrottled):
+		// Neither says anything about the health of the provider.
+		p.breaker.Ignore()
+	default:
+		p.breaker.Failure()
+		if st := p.breaker.Status(); st.State == breaker.Open {
+			slog.Warn("circuit breaker opened", "provider", p.name, "retry_at", st.RetryAt)
+		}
 	}
+	return err
+}
 
-	return result.Age, nil
+func (p *provider) getWithRetry(ctx context.Context, name string, v any) error {
+	for attempt := 0; ; attempt++ {
+		err := p.attempt(ctx, name, v)
+
+		var rerr *retryableError
+		if !errors.As(err, &rerr) || attempt >= p.cfg.Retries {
+			return err
+		}
+
+		wait := rerr.retryAfter
+		switch {
+		case errors.Is(err, ErrQuotaExhausted):
+			// The quota holds the next attempt back until it resets.
+			wait = 0
+		case wait == 0:
+			wait = p.backoff(attempt)
+		}
+		if wait > maxRetryWait {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+
+		slog.Warn("retrying "+p.name, "attempt", attempt+1, "wait", wait, "error", err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("failed to fetch %s: %w", p.name, ctx.Err())
+		case <-timer.C:
+		}
+	}
 }
 
-func fetchGender(name string) (string, error) {
-	url := fmt.Sprintf("https://api.genderize.io/?name=%s", name)
-	resp, err := http.Get(url)
+// backoff returns the wait before the retry following attempt: an
+// exponentially growing interval with its upper half jittered.
+func (p *provider) backoff(attempt int) time.Duration {
+	d := p.cfg.RetryBackoff << attempt
+	if d <= 0 || d > maxRetryWait {
+		d = maxRetryWait
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// attempt makes a single request to the provider.
+func (p *provider) attempt(ctx context.Context, name string, v any) error {
+	// Waiting for the quota must not eat into the request's timeout.
+	if err := p.quota.reserve(ctx, p.name); err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", p.name, err)
+	}
+
+	parent := ctx
+	if p.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.Timeout)
+		defer cancel()
+	}
+
+	query := url.Values{"name": {name}}
+	if p.cfg.APIKey != "" {
+		query.Set("apikey", p.cfg.APIKey)
+	}
+	u := strings.TrimSuffix(p.cfg.BaseURL, "/") + "/?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", p.name, err)
+	}
+	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch age: %w", err)
+		err = fmt.Errorf("failed to fetch %s: %w", p.name, err)
+		if parent.Err() != nil {
+			return err
+		}
+		return &retryableError{err: err}
 	}
 	defer resp.Body.Close()
 
+	p.quota.update(resp)
+	slog.Debug("rate limit", "provider", p.name,
+		"limit", resp.Header.Get("X-Rate-Limit-Limit"),
+		"remaining", resp.Header.Get("X-Rate-Limit-Remaining"),
+		"reset", resp.Header.Get("X-Rate-Limit-Reset"),
+	)
+
 	if resp.StatusCode != http.StatusOK {
-		slog.Error("failed to fetch age", "status_code", resp.StatusCode)
-		return "", fmt.Errorf("failed to fetch nation: %d", resp.StatusCode)
+		slog.Error("failed to fetch "+p.name, "status_code", resp.StatusCode)
+		err := fmt.Errorf("failed to fetch %s: %s", p.name, resp.Status)
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			if qerr := p.quota.exhausted(p.name); qerr != nil {
+				err = fmt.Errorf("failed to fetch %s: %w", p.name, qerr)
+			}
+			return &retryableError{err: err, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		case resp.StatusCode >= 500:
+			return &retryableError{err: err, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		}
+		return err
+	}
 
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", p.name, err)
 	}
+	return nil
+}
 
-	slog.Info("api.genderize.io",
-		"X-Rate-Limit-Remaining", resp.Header.Get("X-Rate-Limit-Remaining"),
-	)
+// parseRetryAfter returns the wait asked for by a Retry-After header in
+// either seconds or HTTP date form, or zero if there is none.
+func parseRetryAfter(h string) time.Duration {
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
 
-	var result struct {
-		Gender string `json:"gender"`
+// ProviderStatus describes the circuit breaker and the rate limit of a
+// provider.
+type ProviderStatus struct {
+	Provider string `json:"provider"`
+	breaker.Status
+	Quota *QuotaStatus `json:"quota,omitempty"`
+}
+
+// ProviderReporter is implemented by enrichers that can report the state
+// of their providers.
+type ProviderReporter interface {
+	ProviderStatus() []ProviderStatus
+}
+
+// HTTPEnricher enriches names with the agify.io, genderize.io and
+// nationalize.io APIs or mirrors of them.
+type HTTPEnricher struct {
+	age         *provider
+	gender      *provider
+	nationality *provider
+}
+
+// NewHTTPEnricher returns an Enricher calling the given providers with
+// client, or http.DefaultClient if client is nil.
+func NewHTTPEnricher(client *http.Client, age, gender, nationality ProviderConfig) *HTTPEnricher {
+	if client == nil {
+		client = http.DefaultClient
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+	return &HTTPEnricher{
+		age:         newProvider("age", client, age),
+		gender:      newProvider("gender", client, gender),
+		nationality: newProvider("nationality", client, nationality),
 	}
+}
 
-	return result.Gender, nil
+func (e *HTTPEnricher) ProviderStatus() []ProviderStatus {
+	statuses := make([]ProviderStatus, 0, 3)
+	for _, p := range []*provider{e.age, e.gender, e.nationality} {
+		statuses = append(statuses, ProviderStatus{
+			Provider: p.name,
+			Status:   p.breaker.Status(),
+			Quota:    p.quota.status(),
+		})
+	}
+	return statuses
 }
 
-func fetchNationality(name string) (string, error) {
-	url := fmt.Sprintf("https://api.nationalize.io/?name=%s", name)
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", fmt.Errorf("failed to fetch nation: %w", err)
+func (e *HTTPEnricher) Age(ctx context.Context, name string) (int, error) {
+	var result struct {
+		Age int `json:"age"`
+	}
+	if err := e.age.get(ctx, name, &result); err != nil {
+		return 0, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		slog.Error("failed to fetch nation", "status_code", resp.StatusCode)
-		return "", fmt.Errorf("failed to fetch nation: %d", resp.StatusCode)
+	return result.Age, nil
+}
 
+func (e *HTTPEnricher) Gender(ctx context.Context, name string) (string, error) {
+	var result struct {
+		Gender string `json:"gender"`
+	}
+	if err := e.gender.get(ctx, name, &result); err != nil {
+		return "", err
 	}
 
-	slog.Info("api.genderize.io",
-		"X-Rate-Limit-Remaining", resp.Header.Get("X-Rate-Limit-Remaining"),
-	)
+	return result.Gender, nil
+}
 
+func (e *HTTPEnricher) Nationality(ctx context.Context, name string) (string, error) {
 	var result struct {
 		Country []struct {
 			CountryID string `json:"country_id"`
 		} `json:"country"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := e.nationality.get(ctx, name, &result); err != nil {
 		return "", err
 	}
 
 	if len(result.Country) == 0 {
-		return "", fmt.Errorf("no country found")
+		return "", &ValidationError{Errors: map[string]string{
+			"Name": "nationality could not be determined from the name",
+		}}
 	}
 
 	return result.Country[0].CountryID, nil
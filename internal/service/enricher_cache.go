@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/TheTeemka/TaskNameManager/internal/repo"
+	"github.com/TheTeemka/TaskNameManager/pkg/lru"
+)
+
+// CacheStats counts how enrichment lookups were answered since start.
+type CacheStats struct {
+	// Enabled is false when lookups are not cached at all.
+	Enabled bool `json:"enabled"`
+	// MemoryHits were answered by the in-process cache, StoreHits by the
+	// persistent one and Misses by the provider.
+	MemoryHits uint64 `json:"memory_hits"`
+	StoreHits  uint64 `json:"store_hits"`
+	Misses     uint64 `json:"misses"`
+	// Size is the number of answers held in process.
+	Size int `json:"size"`
+}
+
+type cacheEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// CachedEnricher answers lookups from an in-process LRU backed by a
+// persistent store before asking the next Enricher. Answers are kept for
+// the TTL and keyed by the lower cased, trimmed name.
+type CachedEnricher struct {
+	next  Enricher
+	store repo.EnrichmentRepository
+	ttl   time.Duration
+	lru   *lru.Cache[string, cacheEntry]
+
+	memoryHits atomic.Uint64
+	storeHits  atomic.Uint64
+	misses     atomic.Uint64
+}
+
+// NewCachedEnricher caches the answers of next for ttl, holding at most
+// size of them in process. store may be nil to only cache in process.
+func NewCachedEnricher(next Enricher, store repo.EnrichmentRepository, size int, ttl time.Duration) *CachedEnricher {
+	return &CachedEnricher{
+		next:  next,
+		store: store,
+		ttl:   ttl,
+		lru:   lru.New[string, cacheEntry](size),
+	}
+}
+
+// Stats returns the hit and miss counts of the cache.
+func (c *CachedEnricher) Stats() CacheStats {
+	return CacheStats{
+		Enabled:    true,
+		MemoryHits: c.memoryHits.Load(),
+		StoreHits:  c.storeHits.Load(),
+		Misses:     c.misses.Load(),
+		Size:       c.lru.Len(),
+	}
+}
+
+func (c *CachedEnricher) Age(ctx context.Context, name string) (int, error) {
+	v, err := c.lookup(ctx, "age", name, func() (string, error) {
+		age, err := c.next.Age(ctx, name)
+		return strconv.Itoa(age), err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(v)
+}
+
+func (c *CachedEnricher) Gender(ctx context.Context, name string) (string, error) {
+	return c.lookup(ctx, "gender", name, func() (string, error) {
+		return c.next.Gender(ctx, name)
+	})
+}
+
+func (c *CachedEnricher) Nationality(ctx context.Context, name string) (string, error) {
+	return c.lookup(ctx, "nationality", name, func() (string, error) {
+		return c.next.Nationality(ctx, name)
+	})
+}
+
+// ProviderStatus reports the providers of the next Enricher, if it can.
+func (c *CachedEnricher) ProviderStatus() []ProviderStatus {
+	if r, ok := c.next.(ProviderReporter); ok {
+		return r.ProviderStatus()
+	}
+	return nil
+}
+
+// lookup returns the cached answer for the attribute of name, or fetches
+// and caches it. Failures of the store are logged and otherwise ignored,
+// the provider is the source of truth.
+func (c *CachedEnricher) lookup(ctx context.Context, attribute, name string, fetch func() (string, error)) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	key := attribute + ":" + name
+	now := time.Now()
+
+	if e, ok := c.lru.Get(key); ok {
+		if now.Before(e.expiresAt) {
+			c.memoryHits.Add(1)
+			slog.Debug("enrichment cache hit", "attribute", attribute, "name", name, "cache", "memory")
+			return e.value, nil
+		}
+		c.lru.Remove(key)
+	}
+
+	if c.store != nil {
+		e, err := c.store.GetEnrichment(ctx, name, attribute)
+		switch {
+		case err == nil && now.Before(e.FetchedAt.Add(c.ttl)):
+			c.storeHits.Add(1)
+			c.lru.Add(key, cacheEntry{value: e.Value, expiresAt: e.FetchedAt.Add(c.ttl)})
+			slog.Debug("enrichment cache hit", "attribute", attribute, "name", name, "cache", "store")
+			return e.Value, nil
+		case err != nil && !errors.Is(err, repo.ErrNotFound):
+			slog.Warn("enrichment cache", "error", err)
+		}
+	}
+
+	c.misses.Add(1)
+	slog.Debug("enrichment cache miss", "attribute", attribute, "name", name)
+	value, err := fetch()
+	if err != nil {
+		return "", err
+	}
+
+	fetchedAt := time.Now()
+	if c.store != nil {
+		e := &repo.Enrichment{Name: name, Attribute: attribute, Value: value}
+		if err := c.store.SaveEnrichment(ctx, e); err != nil {
+			slog.Warn("enrichment cache", "error", err)
+		} else {
+			fetchedAt = e.FetchedAt
+		}
+	}
+	c.lru.Add(key, cacheEntry{value: value, expiresAt: fetchedAt.Add(c.ttl)})
+	return value, nil
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// quota tracks the rate limit a provider reports in its X-Rate-Limit-Limit,
+// X-Rate-Limit-Remaining and X-Rate-Limit-Reset headers. Once fewer than
+// throttleBelow of the limit remain, calls are queued so that the rest of
+// the quota is spread evenly until it resets.
+type quota struct {
+	mu            sync.Mutex
+	throttleBelow float64
+	// known is false until a response carried the headers and again once
+	// the window they described has passed.
+	known     bool
+	limit     int
+	remaining int
+	resetAt   time.Time
+	// nextAt is the earliest time the next throttled call may be made.
+	nextAt time.Time
+}
+
+// QuotaStatus is the rate limit last reported by a provider.
+type QuotaStatus struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// reserve takes a call from the quota, waiting for its turn while the
+// quota runs low. The wait may last until the deadline of ctx, or
+// maxRetryWait if it has none. A turn later than that fails without
+// waiting: with a QuotaError if the quota is used up, otherwise with a
+// ThrottledError.
+func (q *quota) reserve(ctx context.Context, provider string) error {
+	q.mu.Lock()
+	now := time.Now()
+	if q.known && !now.Before(q.resetAt) {
+		q.known = false
+	}
+	if !q.known {
+		q.mu.Unlock()
+		return nil
+	}
+
+	var slot time.Time
+	switch {
+	case q.remaining <= 0:
+		slot = q.resetAt
+	case float64(q.remaining) < q.throttleBelow*float64(q.limit) && q.nextAt.After(now):
+		slot = q.nextAt
+	default:
+		slot = now
+	}
+
+	latest, ok := ctx.Deadline()
+	if !ok {
+		latest = now.Add(maxRetryWait)
+	}
+	if slot.After(latest) {
+		defer q.mu.Unlock()
+		if q.remaining <= 0 {
+			return &QuotaError{Provider: provider, ResetAt: q.resetAt}
+		}
+		return &ThrottledError{Provider: provider, RetryAt: slot}
+	}
+	if q.remaining > 0 {
+		q.nextAt = slot.Add(q.resetAt.Sub(slot) / time.Duration(q.remaining))
+		q.remaining--
+	}
+	q.mu.Unlock()
+
+	wait := slot.Sub(now)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// update records the rate limit headers of a response. A 429 without them
+// exhausts the quota until Retry-After.
+func (q *quota) update(resp *http.Response) {
+	limit, errLimit := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Limit"))
+	remaining, errRemaining := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Remaining"))
+	reset, errReset := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Reset"))
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	switch {
+	case errLimit == nil && errRemaining == nil && errReset == nil:
+		q.known = true
+		q.limit, q.remaining = limit, remaining
+		q.resetAt = time.Now().Add(time.Duration(reset) * time.Second)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		if wait := parseRetryAfter(resp.Header.Get("Retry-After")); wait > 0 {
+			q.known = true
+			q.remaining = 0
+			q.resetAt = time.Now().Add(wait)
+		}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		q.remaining = 0
+	}
+}
+
+// exhausted returns a QuotaError if the quota is known to be used up.
+func (q *quota) exhausted(provider string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.known || q.remaining > 0 {
+		return nil
+	}
+	return &QuotaError{Provider: provider, ResetAt: q.resetAt}
+}
+
+// status returns the last reported rate limit, or nil if there is none.
+func (q *quota) status() *QuotaStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.known || !time.Now().Before(q.resetAt) {
+		return nil
+	}
+	return &QuotaStatus{Limit: q.limit, Remaining: q.remaining, ResetAt: q.resetAt}
+}
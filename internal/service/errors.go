@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TheTeemka/TaskNameManager/pkg/validator"
+)
+
+var (
+	// ErrPreconditionFailed is returned when an update was made against a
+	// version of the person that is no longer current.
+	ErrPreconditionFailed = errors.New("person has been modified since it was read")
+	// ErrValidation matches every ValidationError.
+	ErrValidation = errors.New("validation failed")
+	// ErrUpstreamUnavailable is returned when a person could not be
+	// enriched because a provider failed or could not be reached.
+	ErrUpstreamUnavailable = errors.New("enrichment provider unavailable")
+	// ErrUpstreamTimeout is returned when an enrichment provider did not
+	// answer before its timeout or the enrichment deadline.
+	ErrUpstreamTimeout = errors.New("enrichment provider timed out")
+	// ErrQuotaExhausted is returned when a provider's rate limit leaves no
+	// room for a lookup before it resets.
+	ErrQuotaExhausted = errors.New("enrichment provider quota exhausted")
+	// ErrThrottled is returned when calls to a provider are being spread
+	// out to save its remaining quota and none is due before the deadline.
+	ErrThrottled = errors.New("enrichment provider throttled")
+)
+
+// ValidationError reports the invalid fields of a request, keyed by field.
+type ValidationError struct {
+	Errors map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for key, msg := range e.Errors {
+		msgs = append(msgs, key+": "+msg)
+	}
+	sort.Strings(msgs)
+	return fmt.Sprintf("%s: %s", ErrValidation, strings.Join(msgs, ", "))
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// validationError returns the errors of v as a ValidationError, or nil if
+// there are none.
+func validationError(v *validator.Validator) error {
+	if v.Valid() {
+		return nil
+	}
+	return &ValidationError{Errors: v.Errors}
+}
+
+// UpstreamError reports the enrichment providers that failed a lookup. It
+// matches ErrUpstreamUnavailable, and ErrUpstreamTimeout if they timed out.
+type UpstreamError struct {
+	Providers []string
+	Timeout   bool
+	Err       error
+}
+
+func (e *UpstreamError) Error() string {
+	cause := ErrUpstreamUnavailable
+	if e.Timeout {
+		cause = ErrUpstreamTimeout
+	}
+	return fmt.Sprintf("%s: %s: %v", strings.Join(e.Providers, ", "), cause, e.Err)
+}
+
+func (e *UpstreamError) Is(target error) bool {
+	return target == ErrUpstreamUnavailable || e.Timeout && target == ErrUpstreamTimeout
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+// QuotaError reports a provider whose rate limit is used up until ResetAt.
+// It matches ErrQuotaExhausted.
+type QuotaError struct {
+	Provider string
+	ResetAt  time.Time
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: %s until %s", e.Provider, ErrQuotaExhausted, e.ResetAt.Format(time.RFC3339))
+}
+
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExhausted
+}
+
+// ThrottledError reports a provider whose next call is held back until
+// RetryAt to spread its remaining quota. It matches ErrThrottled.
+type ThrottledError struct {
+	Provider string
+	RetryAt  time.Time
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s: %s until %s", e.Provider, ErrThrottled, e.RetryAt.Format(time.RFC3339))
+}
+
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrThrottled
+}
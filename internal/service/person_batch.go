@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/TheTeemka/TaskNameManager/internal/repo"
+)
+
+const (
+	// MaxBatchSize is the largest number of people a batch may create.
+	MaxBatchSize = 5000
+	// batchConcurrency bounds the enrichment lookups run in parallel for
+	// a batch.
+	batchConcurrency = 8
+)
+
+const (
+	BatchStatusCreated = "created"
+	BatchStatusFailed  = "failed"
+	BatchStatusSkipped = "skipped"
+)
+
+// BatchItemResult reports the outcome of one item of a batch.
+type BatchItemResult struct {
+	Index  int               `json:"index"`
+	Status string            `json:"status"`
+	Person *repo.Person      `json:"person,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+	// err is the cause of a failure.
+	err error
+}
+
+func (r *BatchItemResult) fail(err error) {
+	r.Status = BatchStatusFailed
+	r.Person = nil
+	r.err = err
+
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		r.Errors = verr.Errors
+		return
+	}
+	r.Errors = map[string]string{"msg": err.Error()}
+}
+
+// BatchCreate enriches and creates people for reqs, which must already be
+// valid. Items whose result is already set in results (e.g. failed
+// validation) are left alone.
+//
+// In atomic mode nothing is written unless every item is valid and could
+// be enriched, and all people are inserted in one transaction; the other
+// items are then reported as skipped. Otherwise each person is
+// created on its own and failures are reported per item.
+func (s *PersonService) BatchCreate(ctx context.Context, reqs []*CreatePersonReq, results []*BatchItemResult, atomic bool) error {
+	people := make([]*repo.Person, len(reqs))
+	for i, req := range reqs {
+		if results[i] == nil {
+			people[i] = &repo.Person{Name: req.Name, Surname: req.Surname}
+		}
+	}
+	return s.createPeople(ctx, people, results, atomic)
+}
+
+// createPeople enriches and creates the non-nil people, filling in the
+// result at the same index. See BatchCreate for the meaning of atomic.
+func (s *PersonService) createPeople(ctx context.Context, people []*repo.Person, results []*BatchItemResult, atomic bool) error {
+	for i, p := range people {
+		if p != nil {
+			results[i] = &BatchItemResult{Index: i}
+		}
+	}
+
+	if atomic {
+		// Duplicates would only fail the insert after every person has
+		// been enriched, and without saying which item is at fault.
+		if err := s.checkDuplicates(ctx, people, results); err != nil {
+			return err
+		}
+		if skipRemaining(results) {
+			return nil
+		}
+	}
+
+	s.enrichAll(ctx, people, results, atomic)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if atomic {
+		if skipRemaining(results) {
+			return nil
+		}
+
+		var pending []*repo.Person
+		for _, p := range people {
+			if p != nil {
+				pending = append(pending, p)
+			}
+		}
+		if err := s.repo.CreateMany(ctx, pending); err != nil {
+			return err
+		}
+		for i, p := range people {
+			if p != nil {
+				results[i].Status, results[i].Person = BatchStatusCreated, p
+			}
+		}
+		return nil
+	}
+
+	for i, p := range people {
+		if p == nil || results[i].Status == BatchStatusFailed {
+			continue
+		}
+		if _, err := s.repo.Create(ctx, p); err != nil {
+			results[i].fail(err)
+			continue
+		}
+		results[i].Status, results[i].Person = BatchStatusCreated, p
+	}
+	return nil
+}
+
+// checkDuplicates fails the results of people whose name and surname
+// appear earlier in the batch or belong to a live person.
+func (s *PersonService) checkDuplicates(ctx context.Context, people []*repo.Person, results []*BatchItemResult) error {
+	var pending []*repo.Person
+	first := map[[2]string]int{}
+	for i, p := range people {
+		if p == nil {
+			continue
+		}
+		key := [2]string{p.Name, p.Surname}
+		if j, ok := first[key]; ok {
+			results[i].Status = BatchStatusFailed
+			results[i].Errors = map[string]string{"msg": fmt.Sprintf("same name and surname as item %d", j)}
+			continue
+		}
+		first[key] = i
+		pending = append(pending, p)
+	}
+
+	existing, err := s.repo.GetByNames(ctx, pending)
+	if err != nil {
+		return err
+	}
+	for _, p := range existing {
+		results[first[[2]string{p.Name, p.Surname}]].fail(repo.ErrConflict)
+	}
+	return nil
+}
+
+// skipRemaining marks the results that have no status yet as skipped if
+// any item failed, and reports whether it did.
+func skipRemaining(results []*BatchItemResult) bool {
+	failed := slices.ContainsFunc(results, func(r *BatchItemResult) bool {
+		return r != nil && r.Status == BatchStatusFailed
+	})
+	if failed {
+		for _, r := range results {
+			if r != nil && r.Status == "" {
+				r.Status = BatchStatusSkipped
+			}
+		}
+	}
+	return failed
+}
+
+// enrichAll enriches the non-nil people with at most batchConcurrency
+// lookups in flight, marking the results of those that fail. With
+// stopOnFailure the first failure cancels the lookups in flight and no
+// new ones are started; the results of the people not enriched are left
+// without a status.
+func (s *PersonService) enrichAll(ctx context.Context, people []*repo.Person, results []*BatchItemResult, stopOnFailure bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sem := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for i, p := range people {
+		if p == nil {
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := s.enrich(ctx, p)
+			switch {
+			case err == nil:
+			case stopOnFailure && errors.Is(err, context.Canceled) && ctx.Err() != nil:
+				// Cut short by the failure of another person.
+			default:
+				results[i].fail(err)
+				if stopOnFailure {
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/TheTeemka/TaskNameManager/internal/repo"
+)
+
+// enrich fills in the age, gender and nationality of p from its name,
+// keeping the ones that are already set. The lookups run concurrently
+// within enrichTimeout; the first one to fail cancels the others.
+func (s *PersonService) enrich(ctx context.Context, p *repo.Person) error {
+	parent := ctx
+	var cancel context.CancelFunc
+	if s.enrichTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.enrichTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		first    error
+		provider string
+		timedOut []string
+	)
+	lookup := func(name string, fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := fn()
+			if err == nil {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if errors.Is(err, context.DeadlineExceeded) {
+				timedOut = append(timedOut, name)
+			}
+			if first == nil {
+				first, provider = err, name
+				cancel()
+			}
+		}()
+	}
+
+	if p.Age == 0 {
+		lookup("age", func() (err error) {
+			p.Age, err = s.enricher.Age(ctx, p.Name)
+			return err
+		})
+	}
+	if p.Gender == "" {
+		lookup("gender", func() (err error) {
+			p.Gender, err = s.enricher.Gender(ctx, p.Name)
+			return err
+		})
+	}
+	if p.Nationality == "" {
+		lookup("nationality", func() (err error) {
+			p.Nationality, err = s.enricher.Nationality(ctx, p.Name)
+			return err
+		})
+	}
+	wg.Wait()
+
+	var qerr *QuotaError
+	var terr *ThrottledError
+	switch {
+	case first == nil:
+		return nil
+	case parent.Err() != nil && !errors.Is(parent.Err(), context.DeadlineExceeded):
+		// The caller gave up; no provider is to blame.
+		return parent.Err()
+	case errors.Is(first, ErrValidation):
+		return first
+	case errors.As(first, &qerr):
+		return qerr
+	case errors.As(first, &terr):
+		return terr
+	case len(timedOut) > 0:
+		return &UpstreamError{Providers: timedOut, Timeout: true, Err: first}
+	}
+	return &UpstreamError{Providers: []string{provider}, Err: first}
+}
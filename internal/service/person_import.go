@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/TheTeemka/TaskNameManager/internal/repo"
+	"github.com/TheTeemka/TaskNameManager/pkg/validator"
+)
+
+var importColumns = []string{"name", "surname", "age", "gender", "nationality"}
+
+// ErrInvalidImport is returned when the uploaded file is not a CSV the
+// import can read at all, as opposed to individual rows being invalid.
+var ErrInvalidImport = errors.New("invalid import file")
+
+// ImportRow reports the outcome of one CSV row. Line counts the header as
+// line 1.
+type ImportRow struct {
+	Line    int               `json:"line"`
+	Name    string            `json:"name"`
+	Surname string            `json:"surname"`
+	Person  *repo.Person      `json:"person,omitempty"`
+	Reason  string            `json:"reason,omitempty"`
+	Errors  map[string]string `json:"errors,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun   bool         `json:"dry_run"`
+	Accepted []*ImportRow `json:"accepted"`
+	Skipped  []*ImportRow `json:"skipped"`
+	Rejected []*ImportRow `json:"rejected"`
+}
+
+// Import registers the people listed in a CSV file with a header of name,
+// surname and optionally age, gender and nationality. Rows naming a person
+// that already exists, or that appeared earlier in the file, are skipped;
+// invalid rows are rejected. With dryRun the rows are checked but nothing
+// is written.
+func (s *PersonService) Import(ctx context.Context, r io.Reader, dryRun bool) (*ImportReport, error) {
+	rows, err := readImport(r)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{
+		DryRun:   dryRun,
+		Accepted: []*ImportRow{},
+		Skipped:  []*ImportRow{},
+		Rejected: []*ImportRow{},
+	}
+
+	var candidates []importRow
+	seen := map[[2]string]int{}
+	for _, row := range rows {
+		if row.Errors != nil {
+			report.Rejected = append(report.Rejected, row.ImportRow)
+			continue
+		}
+
+		key := [2]string{row.req.Name, row.req.Surname}
+		if line, ok := seen[key]; ok {
+			row.Reason = fmt.Sprintf("duplicate of line %d", line)
+			report.Skipped = append(report.Skipped, row.ImportRow)
+			continue
+		}
+		seen[key] = row.Line
+		candidates = append(candidates, row)
+	}
+
+	existing, err := s.existingNames(ctx, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	var accepted []*ImportRow
+	var people []*repo.Person
+	for _, row := range candidates {
+		if existing[[2]string{row.req.Name, row.req.Surname}] {
+			row.Reason = "person already exists"
+			report.Skipped = append(report.Skipped, row.ImportRow)
+			continue
+		}
+
+		accepted = append(accepted, row.ImportRow)
+		people = append(people, &repo.Person{
+			Name:        row.req.Name,
+			Surname:     row.req.Surname,
+			Age:         row.req.Age,
+			Gender:      row.req.Gender,
+			Nationality: row.req.Nationality,
+		})
+	}
+
+	if dryRun {
+		report.Accepted = append(report.Accepted, accepted...)
+		report.sort()
+		return report, nil
+	}
+
+	results := make([]*BatchItemResult, len(people))
+	if err := s.createPeople(ctx, people, results, false); err != nil {
+		return nil, err
+	}
+	for i, res := range results {
+		row := accepted[i]
+		switch {
+		case res.Status == BatchStatusCreated:
+			row.Person = res.Person
+			report.Accepted = append(report.Accepted, row)
+		case errors.Is(res.err, repo.ErrConflict):
+			// Created by someone else since the check above.
+			row.Reason = "person already exists"
+			report.Skipped = append(report.Skipped, row)
+		default:
+			row.Reason, row.Errors = "could not be created", res.Errors
+			report.Rejected = append(report.Rejected, row)
+		}
+	}
+	report.sort()
+	return report, nil
+}
+
+// sort orders the rows of each outcome by line.
+func (r *ImportReport) sort() {
+	byLine := func(a, b *ImportRow) int { return a.Line - b.Line }
+	slices.SortFunc(r.Skipped, byLine)
+	slices.SortFunc(r.Rejected, byLine)
+}
+
+// existingNames returns the names and surnames of rows that belong to a
+// live person.
+func (s *PersonService) existingNames(ctx context.Context, rows []importRow) (map[[2]string]bool, error) {
+	people := make([]*repo.Person, len(rows))
+	for i, row := range rows {
+		people[i] = &repo.Person{Name: row.req.Name, Surname: row.req.Surname}
+	}
+
+	found, err := s.repo.GetByNames(ctx, people)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[[2]string]bool, len(found))
+	for _, p := range found {
+		existing[[2]string{p.Name, p.Surname}] = true
+	}
+	return existing, nil
+}
+
+type importRow struct {
+	*ImportRow
+	req *ImportPersonReq
+}
+
+// readImport parses and validates every row of the CSV. Rows that fail
+// validation carry their errors.
+func readImport(r io.Reader) ([]importRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read header: %v", ErrInvalidImport, err)
+	}
+	index := map[string]int{}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !slices.Contains(importColumns, column) {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidImport, column)
+		}
+		index[column] = i
+	}
+	if _, ok := index["name"]; !ok {
+		return nil, fmt.Errorf("%w: missing name column", ErrInvalidImport)
+	}
+	if _, ok := index["surname"]; !ok {
+		return nil, fmt.Errorf("%w: missing surname column", ErrInvalidImport)
+	}
+
+	var rows []importRow
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidImport, perr.Line, perr.Err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		line, _ := cr.FieldPos(0)
+		if len(rows) == MaxBatchSize {
+			return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidImport, MaxBatchSize)
+		}
+
+		get := func(column string) string {
+			i, ok := index[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		v := validator.New()
+		req := &ImportPersonReq{
+			CreatePersonReq: CreatePersonReq{Name: get("name"), Surname: get("surname")},
+			Gender:          get("gender"),
+			Nationality:     get("nationality"),
+		}
+		if age := get("age"); age != "" {
+			v.CheckWithRules("Age", age, validator.IsInt(32))
+			req.Age, _ = strconv.Atoi(age)
+		}
+		req.Validate(v)
+
+		row := importRow{
+			ImportRow: &ImportRow{Line: line, Name: req.Name, Surname: req.Surname},
+			req:       req,
+		}
+		if !v.Valid() {
+			row.Reason, row.Errors = "invalid row", v.Errors
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
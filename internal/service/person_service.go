@@ -2,97 +2,234 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/TheTeemka/TaskNameManager/internal/repo"
+	"github.com/TheTeemka/TaskNameManager/pkg/validator"
 )
 
 type PersonService struct {
-	repo repo.PersonRepository
+	repo     repo.PersonRepository
+	enricher Enricher
+	// enrichTimeout bounds the enrichment of one person; zero means no
+	// deadline beyond the caller's.
+	enrichTimeout  time.Duration
+	purgeRetention time.Duration
 }
 
-func NewPersonService(rep repo.PersonRepository) *PersonService {
+func NewPersonService(rep repo.PersonRepository, enricher Enricher, enrichTimeout, purgeRetention time.Duration) *PersonService {
 	return &PersonService{
-		repo: rep,
+		repo:           rep,
+		enricher:       enricher,
+		enrichTimeout:  enrichTimeout,
+		purgeRetention: purgeRetention,
 	}
 }
 
-func (s *PersonService) CreatePerson(req *CreatePersonReq) (*repo.Person, error) {
+func (s *PersonService) CreatePerson(ctx context.Context, req *CreatePersonReq) (*repo.Person, error) {
+	v := validator.New()
+	req.Validate(v)
+	if err := validationError(v); err != nil {
+		return nil, err
+	}
+
 	p := &repo.Person{
 		Name:    req.Name,
 		Surname: req.Surname,
 	}
 
-	var err error
-	p.Age, err = fetchAge(p.Name)
+	err := s.enrich(ctx, p)
 	if err != nil {
 		return nil, err
 	}
 
-	p.Gender, err = fetchGender(p.Name)
+	p, err = s.repo.Create(ctx, p)
 	if err != nil {
 		return nil, err
 	}
 
-	p.Nationality, err = fetchNationality(p.Name)
+	return p, nil
+}
+
+func (s *PersonService) GetByFilters(ctx context.Context, filters *repo.Filter) ([]*repo.Person, error) {
+	p, err := s.repo.GetByFilters(ctx, filters)
 	if err != nil {
 		return nil, err
 	}
+	return p, nil
+}
 
-	p, err = s.repo.Create(context.Background(), p)
+func (s *PersonService) CountByFilters(ctx context.Context, filters *repo.Filter) (int64, error) {
+	count, err := s.repo.CountByFilters(ctx, filters)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
+	return count, nil
+}
 
-	return p, nil
+// Export calls fn for every person matching filters, streaming them from
+// the repository.
+func (s *PersonService) Export(ctx context.Context, filters *repo.Filter, fn func(*repo.Person) error) error {
+	return s.repo.StreamByFilters(ctx, filters, fn)
 }
 
-func (s *PersonService) GetByFilters(filters *repo.Filter) ([]*repo.Person, error) {
-	p, err := s.repo.GetByFilters(context.Background(), filters)
-	if err != nil {
-		return nil, err
+// UpdateByFilters applies req to every person matching filters and returns
+// how many people were, or with dryRun would be, updated.
+func (s *PersonService) UpdateByFilters(ctx context.Context, filters *repo.Filter, req *UpdatePersonReq, dryRun bool) (int64, error) {
+	v := validator.New()
+	req.Validate(v)
+	v.Check(!req.IsEmpty(), "msg", "at least one field must be changed")
+	if err := validationError(v); err != nil {
+		return 0, err
 	}
-	return p, nil
+
+	if dryRun {
+		return s.repo.CountByFilters(ctx, filters)
+	}
+
+	changes := &repo.PersonChanges{
+		Name:        req.Name,
+		Surname:     req.Surname,
+		Age:         req.Age,
+		Gender:      req.Gender,
+		Nationality: req.Nationality,
+	}
+	return s.repo.UpdateByFilters(ctx, filters, changes)
 }
 
-func (s *PersonService) GetByID(id int64) (*repo.Person, error) {
-	p, err := s.repo.GetByID(context.Background(), id)
+// DeleteByFilters soft deletes every person matching filters and returns
+// how many people were, or with dryRun would be, deleted.
+func (s *PersonService) DeleteByFilters(ctx context.Context, filters *repo.Filter, dryRun bool) (int64, error) {
+	if dryRun {
+		return s.repo.CountByFilters(ctx, filters)
+	}
+	return s.repo.DeleteByFilters(ctx, filters)
+}
+
+func (s *PersonService) GetByID(ctx context.Context, id int64, fields ...string) (*repo.Person, error) {
+	p, err := s.repo.GetByID(ctx, id, fields...)
 	if err != nil {
 		return nil, err
 	}
 	return p, nil
 }
 
-func (s *PersonService) DeleteByID(id int64) error {
-	err := s.repo.DeleteByID(context.Background(), id)
+func (s *PersonService) DeleteByID(ctx context.Context, id int64) error {
+	err := s.repo.DeleteByID(ctx, id)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *PersonService) UpdateByID(id int64, req *UpdatePersonReq) (*repo.Person, error) {
-	p, err := s.repo.GetByID(context.Background(), id)
+func (s *PersonService) Restore(ctx context.Context, id int64) (*repo.Person, error) {
+	p, err := s.repo.Restore(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	if req.Name != "" {
-		p.Name = req.Name
+	return p, nil
+}
+
+// PurgeDeleted permanently removes people that have been soft deleted for
+// longer than the configured retention.
+func (s *PersonService) PurgeDeleted(ctx context.Context) (int64, error) {
+	n, err := s.repo.Purge(ctx, time.Now().Add(-s.purgeRetention))
+	if err != nil {
+		return 0, err
 	}
-	if req.Surname != "" {
-		p.Surname = req.Surname
+	return n, nil
+}
+
+// UpdateByID applies req to the person. If ifMatch is not nil the person's
+// current version must be one of its values, otherwise
+// ErrPreconditionFailed is returned. The person is locked from reading it
+// until the update is committed.
+func (s *PersonService) UpdateByID(ctx context.Context, id int64, req *UpdatePersonReq, ifMatch []int) (*repo.Person, error) {
+	v := validator.New()
+	req.Validate(v)
+	if err := validationError(v); err != nil {
+		return nil, err
 	}
-	if req.Nationality != "" {
-		p.Nationality = req.Nationality
+
+	var p *repo.Person
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		p, err = s.repo.GetByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if ifMatch != nil && !slices.Contains(ifMatch, p.Version) {
+			return ErrPreconditionFailed
+		}
+		if req.Name != "" {
+			p.Name = req.Name
+		}
+		if req.Surname != "" {
+			p.Surname = req.Surname
+		}
+		if req.Nationality != "" {
+			p.Nationality = req.Nationality
+		}
+		if req.Gender != "" {
+			p.Gender = req.Gender
+		}
+		if req.Age != 0 {
+			p.Age = req.Age
+		}
+
+		err = s.repo.Update(ctx, p)
+		if errors.Is(err, repo.ErrVersionConflict) {
+			return ErrPreconditionFailed
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	if req.Gender != "" {
-		p.Gender = req.Gender
+	return p, nil
+}
+
+func (s *PersonService) GetHistory(ctx context.Context, personID int64, limit, offset int) ([]*repo.PersonHistory, int64, error) {
+	h, err := s.repo.GetHistory(ctx, personID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.repo.CountHistory(ctx, personID)
+	if err != nil {
+		return nil, 0, err
 	}
-	if req.Age != 0 {
-		p.Age = req.Age
+	if total == 0 {
+		// Every person has at least its creation recorded, so an empty
+		// history usually means there is no such person.
+		n, err := s.repo.CountByFilters(ctx, &repo.Filter{IDs: []int64{personID}, IncludeDeleted: true})
+		if err != nil {
+			return nil, 0, err
+		}
+		if n == 0 {
+			return nil, 0, fmt.Errorf("failed to get person history: %w", repo.ErrNotFound)
+		}
 	}
-	p.UpdatedAt = time.Now()
+	return h, total, nil
+}
 
-	err = s.repo.Update(context.Background(), p)
-	return p, nil
+// EnrichmentCacheStats reports how enrichment lookups were answered. The
+// stats are zero if the enricher does not cache.
+func (s *PersonService) EnrichmentCacheStats() CacheStats {
+	if c, ok := s.enricher.(*CachedEnricher); ok {
+		return c.Stats()
+	}
+	return CacheStats{}
+}
+
+// EnrichmentProviders reports the circuit breaker state of each
+// enrichment provider.
+func (s *PersonService) EnrichmentProviders() []ProviderStatus {
+	if r, ok := s.enricher.(ProviderReporter); ok {
+		return r.ProviderStatus()
+	}
+	return nil
 }
@@ -12,6 +12,22 @@ func (c *CreatePersonReq) Validate(v *validator.Validator) {
 	v.CheckWithRules("Surname", c.Surname, validator.IsValidLength(1, 20))
 }
 
+// ImportPersonReq is a row of a CSV import. Age, Gender and Nationality
+// override the enriched values when set.
+type ImportPersonReq struct {
+	CreatePersonReq
+	Age         int
+	Gender      string
+	Nationality string
+}
+
+func (c *ImportPersonReq) Validate(v *validator.Validator) {
+	c.CreatePersonReq.Validate(v)
+	v.Check(c.Age >= 0, "Age", "must not be negative")
+	v.CheckWithRules("Gender", c.Gender, validator.IsValidLength(0, 10))
+	v.CheckWithRules("Nationality", c.Nationality, validator.IsValidLength(0, 10))
+}
+
 type UpdatePersonReq struct {
 	Name        string `json:"name"`
 	Surname     string `json:"surname"`
@@ -20,9 +36,14 @@ type UpdatePersonReq struct {
 	Nationality string `json:"nationality"`
 }
 
+func (c *UpdatePersonReq) IsEmpty() bool {
+	return *c == UpdatePersonReq{}
+}
+
 func (c *UpdatePersonReq) Validate(v *validator.Validator) {
 	v.CheckWithRules("Name", c.Name, validator.IsValidLength(0, 20))
 	v.CheckWithRules("Surname", c.Surname, validator.IsValidLength(0, 20))
+	v.Check(c.Age >= 0, "Age", "must not be negative")
 	v.CheckWithRules("Gender", c.Gender, validator.IsValidLength(0, 10))
 	v.CheckWithRules("Nationality", c.Nationality, validator.IsValidLength(0, 10))
 }
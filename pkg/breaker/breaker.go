@@ -0,0 +1,121 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned by Allow while the breaker is open.
+var ErrOpen = errors.New("circuit breaker is open")
+
+type State string
+
+const (
+	// Closed lets every call through.
+	Closed State = "closed"
+	// Open fails every call until the cooldown has passed.
+	Open State = "open"
+	// HalfOpen lets a single trial call through; its outcome closes or
+	// reopens the breaker.
+	HalfOpen State = "half-open"
+)
+
+// Breaker stops calls to a failing dependency after threshold consecutive
+// failures and lets a trial call through once cooldown has passed. It is
+// safe for concurrent use.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     State
+	failures  int
+	openedAt  time.Time
+	trial     bool
+}
+
+// New returns a closed breaker. A threshold of zero or less never opens.
+func New(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     Closed,
+	}
+}
+
+// Allow reports whether a call may be made. Every allowed call must be
+// followed by Success or Failure.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.current() {
+	case Open:
+		return ErrOpen
+	case HalfOpen:
+		if b.trial {
+			return ErrOpen
+		}
+		b.state, b.trial = HalfOpen, true
+	}
+	return nil
+}
+
+// Success records a call that succeeded and closes the breaker.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state, b.failures, b.trial = Closed, 0, false
+}
+
+// Failure records a call that failed, opening the breaker if it was a
+// trial call or the threshold has been reached.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.trial || b.threshold > 0 && b.failures >= b.threshold {
+		b.state, b.openedAt, b.trial = Open, time.Now(), false
+	}
+}
+
+// Ignore records a call whose outcome says nothing about the dependency,
+// e.g. one the caller gave up on.
+func (b *Breaker) Ignore() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
+
+// Status describes the breaker at a point in time.
+type Status struct {
+	State State `json:"state"`
+	// Failures is the number of consecutive failed calls.
+	Failures int `json:"failures"`
+	// RetryAt is when an open breaker lets a trial call through.
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+}
+
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := Status{State: b.current(), Failures: b.failures}
+	if s.State == Open {
+		retryAt := b.openedAt.Add(b.cooldown)
+		s.RetryAt = &retryAt
+	}
+	return s
+}
+
+// current returns the state, moving an open breaker to half-open once the
+// cooldown has passed.
+func (b *Breaker) current() State {
+	if b.state == Open && time.Since(b.openedAt) >= b.cooldown {
+		b.state = HalfOpen
+	}
+	return b.state
+}
@@ -4,14 +4,15 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"time"
 )
 
 var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 var integerRegex = regexp.MustCompile(`^-?[0-9]+$`)
 
-type stringRule func(string) (bool, string)
+type StringRule func(string) (bool, string)
 
-func (v *Validator) CheckWithRules(key, data string, rules ...stringRule) {
+func (v *Validator) CheckWithRules(key, data string, rules ...StringRule) {
 	for _, rule := range rules {
 		ok, msg := rule(data)
 		if !ok {
@@ -20,7 +21,7 @@ func (v *Validator) CheckWithRules(key, data string, rules ...stringRule) {
 	}
 }
 
-func IsValidLength(min, max int) stringRule {
+func IsValidLength(min, max int) StringRule {
 	return func(data string) (bool, string) {
 		if min > max {
 			return false, "internal err: set min length is bigger than set max lenght"
@@ -52,14 +53,14 @@ func IsValidEmail(email string) (bool, string) {
 }
 
 // bitSize 0 means any size; 0 <= bitSize <= 64
-func IsInt(bitSize int) stringRule {
+func IsInt(bitSize int) StringRule {
 	return func(data string) (bool, string) {
 		if !integerRegex.MatchString(data) {
 			return false, "must be a valid integer"
 		}
 
 		if bitSize > 0 {
-			_, err := strconv.ParseInt(data, 10, 64)
+			_, err := strconv.ParseInt(data, 10, bitSize)
 			if err != nil {
 				return false, fmt.Sprintf("number is out of %d bit integer range", bitSize)
 			}
@@ -68,3 +69,36 @@ func IsInt(bitSize int) stringRule {
 		return true, ""
 	}
 }
+
+// IsIntBetween accepts integers within [min, max].
+func IsIntBetween(min, max int64) StringRule {
+	return func(data string) (bool, string) {
+		n, err := strconv.ParseInt(data, 10, 64)
+		if err != nil || n < min || n > max {
+			return false, fmt.Sprintf("must be an integer between %d and %d", min, max)
+		}
+		return true, ""
+	}
+}
+
+// DateTimeLayouts are the formats accepted by IsDateTime, tried in order.
+var DateTimeLayouts = []string{time.RFC3339, time.DateOnly}
+
+func IsDateTime(data string) (bool, string) {
+	if _, err := ParseDateTime(data); err != nil {
+		return false, "must be a RFC3339 timestamp or a YYYY-MM-DD date"
+	}
+	return true, ""
+}
+
+func ParseDateTime(data string) (time.Time, error) {
+	var err error
+	for _, layout := range DateTimeLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, data)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
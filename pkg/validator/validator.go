@@ -31,6 +31,14 @@ func (v *Validator) Check(constraint bool, key, message string) {
 	}
 }
 
+// Merge copies the errors of other into v with their keys prefixed, e.g.
+// to report the errors of the i-th item of a list under "[i].".
+func (v *Validator) Merge(prefix string, other *Validator) {
+	for key, message := range other.Errors {
+		v.AddError(prefix+key, message)
+	}
+}
+
 type ErrorWrapper struct {
 	Errors map[string]string `json:"errors"`
 }